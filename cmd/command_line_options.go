@@ -19,6 +19,7 @@ import (
 	"os"
 	"strings"
 
+	"slex/lexer"
 	x "slex/x"
 
 	cli "github.com/urfave/cli/v2"
@@ -29,6 +30,17 @@ func GetCommandLineOptions() cli.App {
 	outputFilename := ""
 	inputFilename := ""
 	packageName := ""
+	format := formatText
+	backend := x.BackendRegexp
+	reference := x.BackendRegexp
+
+	formatFlag := &cli.StringFlag{
+		Name:        "format",
+		Aliases:     []string{"f"},
+		Usage:       "format of errors: text, json or sarif",
+		Value:       formatText,
+		Destination: &format,
+	}
 
 	return cli.App{
 		Name:    "Simple Lexer for goyacc",
@@ -59,10 +71,26 @@ func GetCommandLineOptions() cli.App {
 						Usage:       "go package name to set",
 						Destination: &packageName,
 					},
+					formatFlag,
+					&cli.StringFlag{
+						Name:        "backend",
+						Aliases:     []string{"b"},
+						Usage:       "search of tokens: regexp or dfa (automaton table, without regex)",
+						Value:       x.BackendRegexp,
+						Destination: &backend,
+					},
 				},
 				Action: func(c *cli.Context) error {
 					var data string
 
+					if errFormat := checkFormat(format); errFormat != nil {
+						return errFormat
+					}
+
+					if backend != x.BackendRegexp && backend != x.BackendDFA {
+						return fmt.Errorf("Unknown backend '%s', use regexp or dfa", backend)
+					}
+
 					if packageName == "" {
 						data = strings.Replace(slexTemplate, "package lexer", "package main", 1)
 					} else {
@@ -89,10 +117,27 @@ func GetCommandLineOptions() cli.App {
 					}
 
 					// Generate variable
-					dataToWriteInFile, errParse := x.ParseParameters(string(content), packageName)
+					var dataToWriteInFile, automaton string
+					var errParse error
+
+					if backend == x.BackendDFA {
+						dataToWriteInFile, automaton, errParse = x.ParseParametersDFA(string(content), packageName)
+					} else {
+						dataToWriteInFile, errParse = x.ParseParameters(string(content), packageName)
+					}
 
 					if errParse != nil {
-						return errParse
+						if format == formatText {
+							return errParse
+						}
+
+						diagnostics := []diagnostic{specDiagnostic(inputFilename, errParse)}
+
+						return exitWithDiagnostics(format, nil, diagnostics)
+					}
+
+					if _, errWrite := f.WriteString(automaton); errWrite != nil {
+						return errWrite
 					}
 
 					fmt.Printf("%s", dataToWriteInFile)
@@ -100,6 +145,156 @@ func GetCommandLineOptions() cli.App {
 					return nil
 				},
 			},
+			{
+				Name:      "check",
+				Usage:     "Check lexer file and lex source files with it",
+				ArgsUsage: "[source files...]",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:        "input",
+						Aliases:     []string{"i"},
+						Usage:       "input filename",
+						Destination: &inputFilename,
+						Required:    true,
+					},
+					formatFlag,
+				},
+				Action: func(c *cli.Context) error {
+					if errFormat := checkFormat(format); errFormat != nil {
+						return errFormat
+					}
+
+					content, errInputfile := os.ReadFile(inputFilename)
+
+					if errInputfile != nil {
+						return errInputfile
+					}
+
+					contents := map[string]string{inputFilename: string(content)}
+					diagnostics := []diagnostic{}
+
+					tokensList, warnings, errParse := x.ParseTokenEntries(string(content), nil)
+
+					if errParse != nil {
+						diagnostics = append(diagnostics, specDiagnostic(inputFilename, errParse))
+
+						return exitWithDiagnostics(format, contents, diagnostics)
+					}
+
+					for _, warning := range warnings {
+						diagnostics = append(diagnostics, specDiagnostic(inputFilename, warning))
+					}
+
+					options := lexer.NewLexerOptions()
+					options.ErrorRecovery = true
+					options.Encoding = lexer.EncodingAuto
+					options.ValidateUTF8 = true
+
+					for _, sourceFilename := range c.Args().Slice() {
+						source, errSource := os.ReadFile(sourceFilename)
+
+						if errSource != nil {
+							return errSource
+						}
+
+						contents[sourceFilename] = string(source)
+						options.FileName = sourceFilename
+
+						_, errLexer := lexer.LexerWithOptions(string(source), tokensList, options)
+
+						if errLexer != nil {
+							diagnostics = append(diagnostics, lexerDiagnostics(sourceFilename, errLexer)...)
+						}
+					}
+
+					return exitWithDiagnostics(format, contents, diagnostics)
+				},
+			},
+			{
+				Name:      "diff",
+				Usage:     "Lex source files with two backends and display first different token",
+				ArgsUsage: "[source files...]",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:        "input",
+						Aliases:     []string{"i"},
+						Usage:       "input filename",
+						Destination: &inputFilename,
+						Required:    true,
+					},
+					&cli.StringFlag{
+						Name:        "reference",
+						Aliases:     []string{"r"},
+						Usage:       "reference backend: regexp, automaton or dfa",
+						Value:       x.BackendRegexp,
+						Destination: &reference,
+					},
+					&cli.StringFlag{
+						Name:        "backend",
+						Aliases:     []string{"b"},
+						Usage:       "backend to compare: regexp, automaton or dfa",
+						Value:       x.BackendDFA,
+						Destination: &backend,
+					},
+				},
+				Action: func(c *cli.Context) error {
+					content, errInputfile := os.ReadFile(inputFilename)
+
+					if errInputfile != nil {
+						return errInputfile
+					}
+
+					tokensList, _, errParse := x.ParseTokenEntries(string(content), nil)
+
+					if errParse != nil {
+						return errParse
+					}
+
+					options := lexer.NewLexerOptions()
+					options.ErrorRecovery = true
+
+					referenceBackend, errReference := x.NewBackend(reference, tokensList, options)
+
+					if errReference != nil {
+						return errReference
+					}
+
+					otherBackend, errBackend := x.NewBackend(backend, tokensList, options)
+
+					if errBackend != nil {
+						return errBackend
+					}
+
+					diagnosticOptions := lexer.NewDiagnosticOptions()
+					diagnosticOptions.ContextBefore = 2
+					diagnosticOptions.Color = lexer.DetectColor(os.Stdout)
+					divergences := 0
+
+					for _, sourceFilename := range c.Args().Slice() {
+						source, errSource := os.ReadFile(sourceFilename)
+
+						if errSource != nil {
+							return errSource
+						}
+
+						d := x.CompareBackends(string(source), referenceBackend, otherBackend)
+
+						if d != nil {
+							diagnostic := d.Diagnostic()
+							diagnostic.File = sourceFilename
+
+							fmt.Println(lexer.RenderDiagnostic(string(source), diagnostic, diagnosticOptions))
+							divergences++
+						}
+					}
+
+					if divergences > 0 {
+						return cli.Exit(fmt.Sprintf("%d file(s) with different tokens", divergences), 1)
+					}
+
+					return nil
+				},
+			},
 			{
 				Name:  "example",
 				Usage: "Generate example file",
@@ -113,6 +308,20 @@ func GetCommandLineOptions() cli.App {
 	}
 }
 
+// Write diagnostics on standard output and exit with error if one
+// diagnostic is an error.
+func exitWithDiagnostics(format string, contents map[string]string, diagnostics []diagnostic) error {
+	if errWrite := writeDiagnostics(os.Stdout, format, contents, diagnostics); errWrite != nil {
+		return errWrite
+	}
+
+	if hasError(diagnostics) {
+		return cli.Exit("", 1)
+	}
+
+	return nil
+}
+
 var slexExample = `// This file is an example generate by Simple Lexer cli.
 // First field is identifier to link with %token (terminal) in goyacc. If indentifier start by '_' data is skip.
 // Item are parse in order, becarefull.
@@ -142,6 +351,17 @@ var slexExample = `// This file is an example generate by Simple Lexer cli.
 // <identifier> => function call
 // If <indentifier> start by '_' data is skip
 //
+// Flags
+// -----
+// <identifier> can be followed by flags: <identifier>/<flags>
+// b: token only at beginning of line
+// f: token only if only space or tab before in line
+// i: case-insensitive (hard value, sub-pattern and regex)
+// s: in regex, '.' match also newline
+// m: in regex, '^' and '$' match begin and end of line
+// l: token never continue after end of line, text can be split at end of lines
+//    (lexer in parallel)
+//
 NUMBER     == 123
 _SPACE     ~= (\s)
 _NEWLINE   ~= (\n|\r|\r\n)
@@ -0,0 +1,329 @@
+package cmd
+
+// Copyright 2021 Simple Lexer Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"slex/lexer"
+	x "slex/x"
+)
+
+// Format of diagnostics
+const (
+	formatText  = "text"
+	formatJSON  = "json"
+	formatSarif = "sarif"
+)
+
+// Code of errors
+const (
+	// Error of lexer
+	codeLexer = "L001"
+	// Error of lexer file without position (e.g. function not found)
+	codeSpec = "X000"
+)
+
+// Position in file. Column is in bytes, end column is exclusive.
+type position struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+type textRange struct {
+	Start position `json:"start"`
+	End   position `json:"end"`
+}
+
+// A diagnostic about a file (lexer file or source file)
+type diagnostic struct {
+	Severity string    `json:"severity"`
+	Code     string    `json:"code"`
+	File     string    `json:"file"`
+	Range    textRange `json:"range"`
+	Message  string    `json:"message"`
+	Fix      string    `json:"fix,omitempty"`
+}
+
+// Check format flag
+func checkFormat(format string) error {
+	switch format {
+	case formatText, formatJSON, formatSarif:
+		return nil
+	}
+
+	return fmt.Errorf("Unknown format '%s', use text, json or sarif", format)
+}
+
+// Create a diagnostic at line and column of file.
+func newDiagnostic(severity string, code string, file string, line int, column int, length int, message string) diagnostic {
+	if length < 1 {
+		length = 1
+	}
+
+	return diagnostic{
+		Severity: severity,
+		Code:     code,
+		File:     file,
+		Range: textRange{
+			Start: position{Line: line, Column: column},
+			End:   position{Line: line, Column: column + length},
+		},
+		Message: message,
+	}
+}
+
+// Convert error of x package into diagnostic.
+func specDiagnostic(file string, err error) diagnostic {
+	var specError *x.SpecError
+
+	if !errors.As(err, &specError) {
+		return newDiagnostic(x.SeverityError, codeSpec, file, 1, 1, 1, err.Error())
+	}
+
+	d := newDiagnostic(specError.Severity, specError.Code, file, specError.Line, specError.Column, specError.Length, specError.Message)
+	d.Fix = specError.Fix
+
+	return d
+}
+
+// Convert error of lexer into diagnostics.
+func lexerDiagnostics(file string, err error) []diagnostic {
+	var lexErrors lexer.LexerErrors
+	var lexError *lexer.LexError
+
+	if errors.As(err, &lexErrors) {
+		result := []diagnostic{}
+
+		for _, e := range lexErrors {
+			result = append(result, lexerDiagnostics(file, e)...)
+		}
+
+		return result
+	}
+
+	if errors.As(err, &lexError) {
+		return []diagnostic{newDiagnostic(x.SeverityError, codeLexer, file, lexError.Line, lexError.Column, lexError.Length, lexError.Message)}
+	}
+
+	return []diagnostic{newDiagnostic(x.SeverityError, codeLexer, file, 1, 1, 1, err.Error())}
+}
+
+// Write diagnostics in format. Contents are content of files, to display
+// part of file in text format.
+func writeDiagnostics(w io.Writer, format string, contents map[string]string, diagnostics []diagnostic) error {
+	switch format {
+	case formatJSON:
+		return writeJSON(w, struct {
+			Diagnostics []diagnostic `json:"diagnostics"`
+		}{diagnostics})
+	case formatSarif:
+		return writeJSON(w, newSarifLog(diagnostics))
+	}
+
+	options := lexer.NewDiagnosticOptions()
+
+	for _, d := range diagnostics {
+		content := contents[d.File]
+		message := d.Severity + ": " + d.Message
+
+		if d.Code != "" {
+			message = fmt.Sprintf("%s [%s]", message, d.Code)
+		}
+
+		ld := lexer.Diagnostic{
+			Message: message,
+			File:    d.File,
+			Line:    d.Range.Start.Line,
+			Column:  d.Range.Start.Column,
+			Offset:  offsetOf(content, d.Range.Start.Line, d.Range.Start.Column),
+			Length:  d.Range.End.Column - d.Range.Start.Column,
+		}
+
+		text := lexer.RenderDiagnostic(content, ld, options)
+
+		if d.Fix != "" {
+			text += "\n= fix: " + d.Fix
+		}
+
+		if _, err := fmt.Fprintln(w, text); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Return offset in bytes of line and column (in bytes) in content. Column out
+// of line is start or end of line.
+func offsetOf(content string, line int, column int) int {
+	lineStart := 0
+
+	for currentLine := 1; currentLine < line; currentLine++ {
+		pos := strings.IndexByte(content[lineStart:], '\n')
+
+		if pos == -1 {
+			break
+		}
+
+		lineStart += pos + 1
+	}
+
+	lineEnd := len(content)
+
+	if pos := strings.IndexByte(content[lineStart:], '\n'); pos != -1 {
+		lineEnd = lineStart + pos
+	}
+
+	lineEnd = lineStart + len(strings.TrimSuffix(content[lineStart:lineEnd], "\r"))
+
+	switch offset := lineStart + column - 1; {
+	case offset < lineStart:
+		return lineStart
+	case offset > lineEnd:
+		return lineEnd
+	default:
+		return offset
+	}
+}
+
+func writeJSON(w io.Writer, data interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(data)
+}
+
+// Return true if one diagnostic is an error.
+func hasError(diagnostics []diagnostic) bool {
+	for _, d := range diagnostics {
+		if d.Severity == x.SeverityError {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SARIF 2.1.0 format
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID string `json:"id"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID     string            `json:"ruleId"`
+	Level      string            `json:"level"`
+	Message    sarifMessage      `json:"message"`
+	Locations  []sarifLocation   `json:"locations"`
+	Properties map[string]string `json:"properties,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn"`
+	EndLine     int `json:"endLine"`
+	EndColumn   int `json:"endColumn"`
+}
+
+func newSarifLog(diagnostics []diagnostic) sarifLog {
+	rules := []sarifRule{}
+	results := []sarifResult{}
+	knownRules := map[string]bool{}
+
+	for _, d := range diagnostics {
+		if !knownRules[d.Code] {
+			knownRules[d.Code] = true
+			rules = append(rules, sarifRule{ID: d.Code})
+		}
+
+		result := sarifResult{
+			RuleID:  d.Code,
+			Level:   d.Severity,
+			Message: sarifMessage{Text: d.Message},
+			Locations: []sarifLocation{{
+				PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifactLocation{URI: strings.ReplaceAll(d.File, "\\", "/")},
+					Region: sarifRegion{
+						StartLine:   d.Range.Start.Line,
+						StartColumn: d.Range.Start.Column,
+						EndLine:     d.Range.End.Line,
+						EndColumn:   d.Range.End.Column,
+					},
+				},
+			}},
+		}
+
+		if d.Fix != "" {
+			result.Properties = map[string]string{"suggestedFix": d.Fix}
+		}
+
+		results = append(results, result)
+	}
+
+	return sarifLog{
+		Version: "2.1.0",
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Runs: []sarifRun{{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name:           "slex",
+				InformationURI: "https://github.com/emeric-martineau/slex",
+				Rules:          rules,
+			}},
+			Results: results,
+		}},
+	}
+}
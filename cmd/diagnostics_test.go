@@ -0,0 +1,323 @@
+package cmd
+
+// Copyright 2021 Simple Lexer Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"slex/lexer"
+	x "slex/x"
+
+	"github.com/andreyvit/diff"
+)
+
+func testDiagnostics() []diagnostic {
+	tokensList := []lexer.TokenEntry{
+		lexer.NewRegexValueToken("_SPACE", "(\\s)", -1),
+		lexer.NewRegexValueToken("NUMBER", "([0-9]+)", 1),
+	}
+
+	options := lexer.NewLexerOptions()
+	options.ErrorRecovery = true
+	options.FileName = "test.src"
+
+	_, err := lexer.LexerWithOptions("1 a\r\n2 #", tokensList, options)
+
+	diagnostics := []diagnostic{
+		specDiagnostic("test.x", &x.SpecError{
+			Severity: x.SeverityWarning,
+			Code:     x.CodeRegexAlternation,
+			Line:     1,
+			Column:   6,
+			Length:   3,
+			Message:  "Regex 'a|b' of 'A' has alternation outside parentheses",
+			Fix:      "enclose regex in parentheses: (a|b)",
+		}),
+		specDiagnostic("test.x", errors.New("function 'skip' not found")),
+	}
+
+	return append(diagnostics, lexerDiagnostics("test.src", err)...)
+}
+
+func Test_Lexer_Diagnostics(t *testing.T) {
+	diagnostics := testDiagnostics()
+
+	// One diagnostic by error of LexerErrors
+	if len(diagnostics) != 4 {
+		t.Errorf("Expected 4 diagnostics found %+v", diagnostics)
+		return
+	}
+
+	if d := diagnostics[3]; d.Code != codeLexer || d.Range.Start.Line != 2 || d.Range.Start.Column != 3 || d.Range.End.Column != 4 {
+		t.Errorf("Expected lexer error at 2:3 found %+v", d)
+	}
+
+	if d := diagnostics[1]; d.Code != codeSpec || d.Range.Start.Line != 1 || d.Range.Start.Column != 1 {
+		t.Errorf("Expected spec error at 1:1 found %+v", d)
+	}
+}
+
+func Test_Write_Diagnostics(t *testing.T) {
+	expected := map[string]string{
+		formatText: `test.x:1:6: warning: Regex 'a|b' of 'A' has alternation outside parentheses [X010]
+1 | A ~= a|b
+  |      ^^^
+= fix: enclose regex in parentheses: (a|b)
+test.x:1:1: error: function 'skip' not found [X000]
+1 | A ~= a|b
+  | ^
+test.src:1:3: error: invalid token found [L001]
+1 | 1 a
+  |   ^
+test.src:2:3: error: invalid token found [L001]
+2 | 2 #
+  |   ^
+`,
+		formatJSON: `{
+  "diagnostics": [
+    {
+      "severity": "warning",
+      "code": "X010",
+      "file": "test.x",
+      "range": {
+        "start": {
+          "line": 1,
+          "column": 6
+        },
+        "end": {
+          "line": 1,
+          "column": 9
+        }
+      },
+      "message": "Regex 'a|b' of 'A' has alternation outside parentheses",
+      "fix": "enclose regex in parentheses: (a|b)"
+    },
+    {
+      "severity": "error",
+      "code": "X000",
+      "file": "test.x",
+      "range": {
+        "start": {
+          "line": 1,
+          "column": 1
+        },
+        "end": {
+          "line": 1,
+          "column": 2
+        }
+      },
+      "message": "function 'skip' not found"
+    },
+    {
+      "severity": "error",
+      "code": "L001",
+      "file": "test.src",
+      "range": {
+        "start": {
+          "line": 1,
+          "column": 3
+        },
+        "end": {
+          "line": 1,
+          "column": 4
+        }
+      },
+      "message": "invalid token found"
+    },
+    {
+      "severity": "error",
+      "code": "L001",
+      "file": "test.src",
+      "range": {
+        "start": {
+          "line": 2,
+          "column": 3
+        },
+        "end": {
+          "line": 2,
+          "column": 4
+        }
+      },
+      "message": "invalid token found"
+    }
+  ]
+}
+`,
+		formatSarif: `{
+  "version": "2.1.0",
+  "$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+  "runs": [
+    {
+      "tool": {
+        "driver": {
+          "name": "slex",
+          "informationUri": "https://github.com/emeric-martineau/slex",
+          "rules": [
+            {
+              "id": "X010"
+            },
+            {
+              "id": "X000"
+            },
+            {
+              "id": "L001"
+            }
+          ]
+        }
+      },
+      "results": [
+        {
+          "ruleId": "X010",
+          "level": "warning",
+          "message": {
+            "text": "Regex 'a|b' of 'A' has alternation outside parentheses"
+          },
+          "locations": [
+            {
+              "physicalLocation": {
+                "artifactLocation": {
+                  "uri": "test.x"
+                },
+                "region": {
+                  "startLine": 1,
+                  "startColumn": 6,
+                  "endLine": 1,
+                  "endColumn": 9
+                }
+              }
+            }
+          ],
+          "properties": {
+            "suggestedFix": "enclose regex in parentheses: (a|b)"
+          }
+        },
+        {
+          "ruleId": "X000",
+          "level": "error",
+          "message": {
+            "text": "function 'skip' not found"
+          },
+          "locations": [
+            {
+              "physicalLocation": {
+                "artifactLocation": {
+                  "uri": "test.x"
+                },
+                "region": {
+                  "startLine": 1,
+                  "startColumn": 1,
+                  "endLine": 1,
+                  "endColumn": 2
+                }
+              }
+            }
+          ]
+        },
+        {
+          "ruleId": "L001",
+          "level": "error",
+          "message": {
+            "text": "invalid token found"
+          },
+          "locations": [
+            {
+              "physicalLocation": {
+                "artifactLocation": {
+                  "uri": "test.src"
+                },
+                "region": {
+                  "startLine": 1,
+                  "startColumn": 3,
+                  "endLine": 1,
+                  "endColumn": 4
+                }
+              }
+            }
+          ]
+        },
+        {
+          "ruleId": "L001",
+          "level": "error",
+          "message": {
+            "text": "invalid token found"
+          },
+          "locations": [
+            {
+              "physicalLocation": {
+                "artifactLocation": {
+                  "uri": "test.src"
+                },
+                "region": {
+                  "startLine": 2,
+                  "startColumn": 3,
+                  "endLine": 2,
+                  "endColumn": 4
+                }
+              }
+            }
+          ]
+        }
+      ]
+    }
+  ]
+}
+`,
+	}
+
+	contents := map[string]string{
+		"test.x":   "A ~= a|b\n",
+		"test.src": "1 a\r\n2 #",
+	}
+
+	for _, format := range []string{formatText, formatJSON, formatSarif} {
+		var result strings.Builder
+
+		if err := writeDiagnostics(&result, format, contents, testDiagnostics()); err != nil {
+			t.Errorf("Format %s: an error occure %+v", format, err)
+		}
+
+		if a, e := strings.TrimSpace(result.String()), strings.TrimSpace(expected[format]); a != e {
+			t.Errorf("Format %s: result not as expected:\n%v", format, diff.LineDiff(e, a))
+		}
+	}
+}
+
+func Test_Offset_Of(t *testing.T) {
+	content := "ab\r\ncd\nef"
+	// Line, column and expected offset
+	cases := [][]int{
+		{1, 1, 0},
+		{1, 3, 2},
+		{2, 1, 4},
+		{2, 2, 5},
+		{3, 2, 8},
+		// Column out of line, stay at end of line (before "\r\n")
+		{1, 10, 2},
+		{2, 10, 6},
+		{3, 10, 9},
+		// Column before line
+		{2, 0, 4},
+		// Line out of content
+		{10, 1, 7},
+	}
+
+	for _, c := range cases {
+		if offset := offsetOf(content, c[0], c[1]); offset != c[2] {
+			t.Errorf("Line %d column %d: expected offset %d found %d", c[0], c[1], c[2], offset)
+		}
+	}
+}
@@ -1,6 +1,5 @@
 // Simple basic lang example
-PRINT      == print
-IDENTIFIER ~= ([a-zA-Z]+)
+IDENTIFIER ~= ([a-zA-Z]+)	PRINT=print
 ADD        == +
 NUMBER     ~= ([0-9]+)
 EQUAL      == =
@@ -5,6 +5,7 @@ package main
 
 import (
 	"fmt"
+	"os"
 	"strconv"
 )
 
@@ -73,6 +74,7 @@ addition : number ADD number
 %%      /*  start  of  programs  */
 
 type BasicLex struct {
+	Source string
 	Tokens []Token
 	Index int
 }
@@ -90,21 +92,37 @@ func (l *BasicLex) Lex(lval *BasicSymType) int {
 }
 
 func (l *BasicLex) Error(s string) {
-	fmt.Printf("syntax error: %s\n", s)
+	options := NewDiagnosticOptions()
+	options.Color = DetectColor(os.Stdout)
+
+	diagnostic := NewTokenDiagnostic(currentToken, s)
+
+	fmt.Println(RenderDiagnostic(l.Source, diagnostic, options))
+
+	for _, token := range l.Tokens[:l.Index] {
+		if token.Suggestion != "" {
+			fmt.Printf("did you mean `%s` instead of `%s`?\n", token.Suggestion, token.Data)
+		}
+	}
 }
 
 func main() {
 	BasicDebug = 0
 	BasicErrorVerbose = true
 
-    tokens, err := Lexer("print 123 + 2 + 3", tokensList)
+	source := "print 123 + 2 + 3"
+	options := NewLexerOptions()
+	options.SuggestDistance = 2
+
+	tokens, err := LexerWithOptions(source, tokensList, options)
 
 	if err != nil {
-		fmt.Printf("Error: %s", err.Error())
+		fmt.Println(err.Error())
 		return
 	}
 
 	lex := BasicLex {
+		Source: source,
 		Tokens: tokens,
 		Index: 0,
 	}
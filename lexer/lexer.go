@@ -15,12 +15,25 @@ package lexer
 // limitations under the License.
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
 	"regexp"
+	"regexp/syntax"
+	"runtime"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
 )
 
-// Log level
+// Log level (see LexerOptions.LogLevel)
 const (
 	LexerLogNone = iota
 	LexerLogError
@@ -29,9 +42,6 @@ const (
 	LexerLogDebug
 )
 
-// LexerLogLevel define log level of lexer
-var LexerLogLevel = LexerLogError
-
 // <identifier> == hard value
 // <identifier> ~= regex [ function call | ID=value ID=value ]
 // <identifier> => function call
@@ -45,6 +55,24 @@ const (
 // SkipToken is value to use to ask this token must be skip
 const SkipToken int = -1
 
+// Flags of token entry. Can be combined.
+const (
+	// FlagLineStart token is only search at beginning of line
+	FlagLineStart = 1 << iota
+	// FlagFirstNonBlank token is only search if only blank characters are
+	// before in line
+	FlagFirstNonBlank
+	// FlagCaseInsensitive hard value, sub pattern and regex ignore case
+	FlagCaseInsensitive
+	// FlagDotNewLine in regex, '.' match also '\n'
+	FlagDotNewLine
+	// FlagMultiLine in regex, '^' and '$' match begin and end of line
+	FlagMultiLine
+	// FlagSingleLine token never continue after end of line (e.g. function
+	// call), text can be split at end of lines (see LexerParallel)
+	FlagSingleLine
+)
+
 // SubPattern is sub stype for SubPatternValue
 type SubPattern struct {
 	// Name of token
@@ -72,12 +100,14 @@ type TokenEntry struct {
 	SubValue []SubPattern
 	// IDValue is generate by yacc
 	IDValue int
+	// Flags of token (FlagLineStart...)
+	Flags int
 	// Only for regex and for performance
 	m *regexp.Regexp
+	// First bytes of text that token can match, nil if not computed
+	first *byteSet
 }
 
-var endLineRegex = regexp.MustCompile("(\n|\\r\\n)")
-
 // NewHardValueToken create a token to search
 func NewHardValueToken(name string, value string, idValue int) TokenEntry {
 	return TokenEntry{
@@ -87,6 +117,7 @@ func NewHardValueToken(name string, value string, idValue int) TokenEntry {
 		FnCallback: nil,
 		SubValue:   nil,
 		IDValue:    idValue,
+		first:      firstBytes(HardValue, value, 0),
 	}
 }
 
@@ -111,7 +142,8 @@ func NewRegexValueToken(name string, value string, idValue int) TokenEntry {
 		FnCallback: nil,
 		SubValue:   nil,
 		IDValue:    idValue,
-		m:          regexp.MustCompile("^" + value),
+		m:          compileRegex(value, 0),
+		first:      firstBytes(RegexValue, value, 0),
 	}
 }
 
@@ -124,7 +156,8 @@ func NewRegexWithSubValueToken(name string, value string, subValue []SubPattern,
 		FnCallback: nil,
 		SubValue:   subValue,
 		IDValue:    idValue,
-		m:          regexp.MustCompile("^" + value),
+		m:          compileRegex(value, 0),
+		first:      firstBytes(RegexValue, value, 0),
 	}
 }
 
@@ -137,15 +170,171 @@ func NewRegexWithSubValueFnToken(name string, value string, fnCallback TokenCall
 		FnCallback: fnCallback,
 		SubValue:   nil,
 		IDValue:    idValue,
-		m:          regexp.MustCompile("^" + value),
+		m:          compileRegex(value, 0),
+		first:      firstBytes(RegexValue, value, 0),
+	}
+}
+
+// WithFlags return a copy of token entry with flags added
+func (t TokenEntry) WithFlags(flags int) TokenEntry {
+	t.Flags |= flags
+
+	if t.TypeOf == RegexValue {
+		t.m = compileRegex(t.Value, t.Flags)
+	}
+
+	if t.TypeOf != FunctionCall {
+		t.first = firstBytes(t.TypeOf, t.Value, t.Flags)
+	}
+
+	return t
+}
+
+// Compile regex with flags. Regex always start at first position, also each
+// branch of alternation (e.g. a|b).
+func compileRegex(value string, flags int) *regexp.Regexp {
+	return regexp.MustCompile("^(?:" + regexFlags(flags) + value + ")")
+}
+
+// Return flags of regex, e.g. (?i)
+func regexFlags(flags int) string {
+	regexFlags := ""
+
+	if flags&FlagCaseInsensitive != 0 {
+		regexFlags += "i"
+	}
+
+	if flags&FlagDotNewLine != 0 {
+		regexFlags += "s"
+	}
+
+	if flags&FlagMultiLine != 0 {
+		regexFlags += "m"
+	}
+
+	if regexFlags != "" {
+		regexFlags = "(?" + regexFlags + ")"
+	}
+
+	return regexFlags
+}
+
+// Return sub pattern value near token data, if token is not a sub pattern.
+// Value is near if distance is lower or equal maxDistance and lower than half
+// of value, to not suggest for short words.
+func (t *TokenEntry) suggestKeyword(token Token, maxDistance int) string {
+	if t.TypeOf != RegexValue || t.SubValue == nil || token.Name != t.Name {
+		return ""
+	}
+
+	data := token.Data
+
+	if t.Flags&FlagCaseInsensitive != 0 {
+		data = strings.ToLower(data)
+	}
+
+	suggestion := ""
+	bestDistance := maxDistance + 1
+
+	for _, subValue := range t.SubValue {
+		value := subValue.Value
+
+		if t.Flags&FlagCaseInsensitive != 0 {
+			value = strings.ToLower(value)
+		}
+
+		distance := editDistance(data, value)
+
+		if distance > 0 && distance < bestDistance && distance*2 < utf8.RuneCountInString(value) {
+			suggestion = subValue.Value
+			bestDistance = distance
+		}
+	}
+
+	return suggestion
+}
+
+// Levenshtein distance of two strings.
+func editDistance(str1 string, str2 string) int {
+	runes1 := []rune(str1)
+	runes2 := []rune(str2)
+	previous := make([]int, len(runes2)+1)
+	current := make([]int, len(runes2)+1)
+
+	for index := range previous {
+		previous[index] = index
+	}
+
+	for index1, char1 := range runes1 {
+		current[0] = index1 + 1
+
+		for index2, char2 := range runes2 {
+			cost := 1
+
+			if char1 == char2 {
+				cost = 0
+			}
+
+			current[index2+1] = min3(previous[index2+1]+1, current[index2]+1, previous[index2]+cost)
+		}
+
+		previous, current = current, previous
+	}
+
+	return previous[len(runes2)]
+}
+
+func min3(a int, b int, c int) int {
+	if b < a {
+		a = b
+	}
+
+	if c < a {
+		a = c
+	}
+
+	return a
+}
+
+// Compare two strings, ignoring case if needed.
+func (t *TokenEntry) equal(value1 string, value2 string) bool {
+	if t.Flags&FlagCaseInsensitive != 0 {
+		return strings.EqualFold(value1, value2)
+	}
+
+	return value1 == value2
+}
+
+// Check if token can be search at this position in line.
+func (t *TokenEntry) isAllowedAt(charPos int, onlyBlankBefore bool) bool {
+	if t.Flags&FlagLineStart != 0 && charPos != 1 {
+		return false
+	}
+
+	if t.Flags&FlagFirstNonBlank != 0 && !onlyBlankBefore {
+		return false
 	}
+
+	return true
 }
 
-// FindStringIndex find an str for regex value
+// FindStringIndex find an str for regex value. If token entry is created
+// without constructor (e.g. with automaton table), regex is compiled at each
+// call.
 func (t *TokenEntry) FindStringIndex(text string) []int {
 	// Token must always start at first position, cause each time of
 	// NextToken() call, previous data skip.
-	return t.m.FindStringIndex(text)
+	return t.regex().FindStringIndex(text)
+}
+
+// Return compiled regex of token, compile it if token entry is created
+// without constructor.
+func (t *TokenEntry) regex() *regexp.Regexp {
+	if t.m == nil {
+		return compileRegex(t.Value, t.Flags)
+	}
+
+	return t.m
 }
 
 // Token token found, first pos, last pos
@@ -160,8 +349,19 @@ type Token struct {
 	StartPos int
 	// EndPos end position in line
 	Lenght int
+	// Offset is position in bytes of token in text (plus
+	// LexerOptions.StartOffset)
+	Offset int
+	// EndLine line number where token end
+	EndLine int
+	// EndColumn position in line after last character of token
+	EndColumn int
 	// Token data cause regex
 	Data string
+	// Suggestion is a keyword near data (see LexerOptions.SuggestDistance)
+	Suggestion string
+	// File is name of file where token found (see LexerOptions.FileName)
+	File string
 }
 
 // TokenExtraInformation extra data about token
@@ -172,209 +372,2795 @@ type TokenExtraInformation struct {
 	LineIncludeInToken int
 }
 
+// LineDirectiveParser return file name (empty to keep current file) and line
+// number of line after line directive. ok is false if data is not a valid
+// directive.
+type LineDirectiveParser = func(data string) (file string, line int, ok bool)
+
+// IncludeParser return path of file to include. ok is false if data is not a
+// valid include.
+type IncludeParser = func(data string) (path string, ok bool)
+
+// ErrorTokenName is name of token emit for invalid input when error recovery
+// is enable
+const ErrorTokenName = "ERROR"
+
+// ErrorToken is default IDValue of error token
+const ErrorToken int = -2
+
+// RecoveryStrategy return number of bytes to skip in text when no token found
+type RecoveryStrategy = func(text string) int
+
+// End of lines to count line. Can be combined.
+const (
+	// NewlineLF is "\n"
+	NewlineLF = 1 << iota
+	// NewlineCRLF is "\r\n"
+	NewlineCRLF
+	// NewlineCR is "\r" alone (classic Mac)
+	NewlineCR
+	// NewlineUnicode is U+0085, U+2028 and U+2029
+	NewlineUnicode
+	// NewlineFormFeed is "\f"
+	NewlineFormFeed
+)
+
+// NewlineDefault is default end of lines
+const NewlineDefault = NewlineLF | NewlineCRLF
+
+// Encoding of text
+const (
+	// EncodingUTF8 text is UTF-8
+	EncodingUTF8 = iota
+	// EncodingUTF16LE text is UTF-16 little endian
+	EncodingUTF16LE
+	// EncodingUTF16BE text is UTF-16 big endian
+	EncodingUTF16BE
+	// EncodingAuto detect UTF-16 with BOM, UTF-8 if no BOM
+	EncodingAuto
+)
+
+// Byte order mark
+const (
+	bomUTF8    = "\xEF\xBB\xBF"
+	bomUTF16LE = "\xFF\xFE"
+	bomUTF16BE = "\xFE\xFF"
+)
+
+// Unit of column in line (Token.StartPos, Token.EndColumn...)
+const (
+	// ColumnBytes column is number of bytes
+	ColumnBytes = iota
+	// ColumnRunes column is number of unicode characters
+	ColumnRunes
+	// ColumnUTF16 column is number of UTF-16 code units (like LSP)
+	ColumnUTF16
+	// ColumnDisplay column is number of columns on screen, with tab
+	// expanded and wide characters
+	ColumnDisplay
+)
+
+// LexerOptions options of lexer
+type LexerOptions struct {
+	// ErrorRecovery if true, lexer emit an error token for invalid input and
+	// continue instead of stop at first error
+	ErrorRecovery bool
+	// ErrorIDValue is IDValue of error token, ErrorToken by default. SkipToken
+	// to not emit token.
+	ErrorIDValue int
+	// Recovery is strategy to skip invalid input
+	Recovery RecoveryStrategy
+	// MaxErrors stop lexer when number of errors is reached. 0 for no limit.
+	MaxErrors int
+	// FileName is name of file lexed, used in errors and tokens
+	FileName string
+	// StartLine is line number of start of text, when text is a part of a
+	// file
+	StartLine int
+	// StartColumn is column of start of text in first line
+	StartColumn int
+	// StartOffset is position in bytes of start of text in file
+	StartOffset int
+	// LineDirective is name of rule of line directives (e.g. #line 42 "a.c").
+	// Line after directive has line number and file of directive.
+	LineDirective string
+	// LineDirectiveParser read line directive
+	LineDirectiveParser LineDirectiveParser
+	// Include is name of rule of include (e.g. include "other.conf"). Tokens
+	// of included file are added after include token.
+	Include string
+	// IncludeFS is file system to read included files. Path is relative to
+	// directory of current file.
+	IncludeFS fs.FS
+	// IncludeParser read include
+	IncludeParser IncludeParser
+	// MaxIncludeDepth is maximum number of nested included files
+	MaxIncludeDepth int
+	// Automaton search all tokens in one pass. Must be created with same
+	// list of tokens.
+	Automaton *Automaton
+	// LongestMatch if true, token is longest token found, first one in list
+	// if same length. Otherwise, token is first token found in list.
+	LongestMatch bool
+	// Diagnostic options to render errors
+	Diagnostic DiagnosticOptions
+	// SuggestDistance if greater than 0, set Token.Suggestion with nearest
+	// sub pattern of regex, if distance of token with it is lower or equal
+	SuggestDistance int
+	// ColumnUnit is unit of column (ColumnBytes, ColumnRunes...)
+	ColumnUnit int
+	// TabWidth number of columns of a tab with ColumnDisplay
+	TabWidth int
+	// Newlines is end of lines used to count line (NewlineLF...)
+	Newlines int
+	// NormalizeNewlines if true, all end of lines in Token.Data are
+	// replaced by '\n'
+	NormalizeNewlines bool
+	// Encoding of text (EncodingUTF8...). UTF-16 text is converted in UTF-8
+	// before lexing, Token.Offset is position in converted text.
+	Encoding int
+	// StripBOM if true, byte order mark at start of text is removed
+	StripBOM bool
+	// ValidateUTF8 if true, invalid UTF-8 sequence is an error
+	ValidateUTF8 bool
+	// Workers is number of goroutines of LexFiles and LexerParallel. 0 for
+	// GOMAXPROCS.
+	Workers int
+	// ChunkSize is minimum size in bytes of parts of text lexed by
+	// LexerParallel
+	ChunkSize int
+	// LogLevel is level of messages written in Logger (LexerLogNone...)
+	LogLevel int
+	// Logger write messages of lexer, nil to disable log
+	Logger Logger
+}
+
+// LexError is error found by lexer
+type LexError struct {
+	// Message of error
+	Message string
+	// File name of text (see LexerOptions.FileName)
+	File string
+	// Line where error found
+	Line int
+	// Column where error found
+	Column int
+	// Offset is position in bytes of error in text
+	Offset int
+	// Length is length in bytes of invalid text
+	Length int
+	// Snippet is line of text with position of error
+	Snippet string
+	// RulesTried is name of rules tried at this position
+	RulesTried []string
+}
+
+func (e *LexError) Error() string {
+	return e.Diagnostic().header() + "\n" + e.Snippet
+}
+
+// Diagnostic return diagnostic of error, to render it with other options
+func (e *LexError) Diagnostic() Diagnostic {
+	return Diagnostic{
+		Message: e.Message,
+		File:    e.File,
+		Line:    e.Line,
+		Column:  e.Column,
+		Offset:  e.Offset,
+		Length:  e.Length,
+	}
+}
+
+// LexerErrors is list of errors found when error recovery is enable
+type LexerErrors []error
+
+func (e LexerErrors) Error() string {
+	messages := make([]string, len(e))
+
+	for index, err := range e {
+		messages[index] = err.Error()
+	}
+
+	return strings.Join(messages, "\n")
+}
+
+// NewLexerOptions create default options of lexer
+func NewLexerOptions() LexerOptions {
+	return LexerOptions{
+		ErrorRecovery:       false,
+		ErrorIDValue:        ErrorToken,
+		Recovery:            RecoverySkipRune,
+		MaxErrors:           0,
+		StartLine:           1,
+		StartColumn:         1,
+		StartOffset:         0,
+		LineDirective:       "",
+		LineDirectiveParser: ParseLineDirective,
+		Include:             "",
+		IncludeParser:       ParseInclude,
+		MaxIncludeDepth:     16,
+		Automaton:           nil,
+		LongestMatch:        false,
+		Diagnostic:          NewDiagnosticOptions(),
+		SuggestDistance:     0,
+		ColumnUnit:          ColumnBytes,
+		TabWidth:            4,
+		Newlines:            NewlineDefault,
+		NormalizeNewlines:   false,
+		Encoding:            EncodingUTF8,
+		StripBOM:            true,
+		ValidateUTF8:        false,
+		Workers:             0,
+		ChunkSize:           1 << 20,
+		LogLevel:            LexerLogNone,
+		Logger:              nil,
+	}
+}
+
+// RecoverySkipRune skip only one character
+func RecoverySkipRune(text string) int {
+	_, size := utf8.DecodeRuneInString(text)
+
+	return size
+}
+
+// RecoverySkipToSpace skip all characters until next whitespace
+func RecoverySkipToSpace(text string) int {
+	pos := strings.IndexFunc(text, unicode.IsSpace)
+
+	if pos == -1 {
+		return len(text)
+	}
+
+	return pos
+}
+
+// RecoverySkipToEndOfLine skip all characters until end of line
+// (NewlineDefault)
+func RecoverySkipToEndOfLine(text string) int {
+	return RecoverySkipToEndOfLineWith(NewlineDefault)(text)
+}
+
+// RecoverySkipToEndOfLineWith create strategy to skip all characters until
+// end of line. newlines is end of lines (NewlineLF...), use same value as
+// LexerOptions.Newlines.
+func RecoverySkipToEndOfLineWith(newlines int) RecoveryStrategy {
+	return func(text string) int {
+		for index := 0; index < len(text); index++ {
+			if lineEndAt(text, index, newlines) != 0 {
+				return index
+			}
+		}
+
+		return len(text)
+	}
+}
+
 // Lexer read text and convert it in Token
 func Lexer(text string, tokensList []TokenEntry) ([]Token, error) {
-	var currentToken Token
-	var isFound bool
+	return LexerWithOptions(text, tokensList, NewLexerOptions())
+}
+
+// LexerWithOptions read text and convert it in Token with options
+func LexerWithOptions(text string, tokensList []TokenEntry, options LexerOptions) ([]Token, error) {
+	return lexText(text, tokensList, options, []string{options.FileName})
+}
+
+// LexerAppend read text and append tokens to tokens, e.g. tokens[:0] to reuse
+// memory of previous call.
+func LexerAppend(tokens []Token, text string, tokensList []TokenEntry, options LexerOptions) ([]Token, error) {
+	return newScanner(text, tokensList, options, []string{options.FileName}).appendTokens(tokens)
+}
+
+// Read text. includes is stack of files currently read, last is file of text.
+func lexText(text string, tokensList []TokenEntry, options LexerOptions, includes []string) ([]Token, error) {
+	return newScanner(text, tokensList, options, includes).appendTokens([]Token{})
+}
+
+// Scanner read tokens of text one by one, without create list of all tokens.
+// Scanner is not safe for concurrent use.
+type Scanner struct {
+	text       string
+	tokensList []TokenEntry
+	options    LexerOptions
+	// Stack of files currently read, last is file of text
+	includes []string
 	// character position in line
-	charPos := 1
+	charPos int
 	// current line number
-	lineNumber := 1
+	lineNumber int
 	// character position in text
-	charPosInGlobalText := 0
-	// the length of text to stop
-	lenOfText := len(text)
-	// Tokens list
-	tokens := []Token{}
+	charPosInGlobalText int
+	// only space or tab before current position in line
+	onlyBlankBefore bool
+	// Errors found if error recovery enable
+	errs LexerErrors
+	// Position of next invalid UTF-8 sequence
+	nextInvalid int
+	// Index to search tokens
+	index *tokenIndex
+	// Position of end of lines, and next end of line
+	source     *Source
+	lineCursor int
+	// Source to render lines of errors, text start at snippetStart in it
+	// (e.g. whole text of LexerParallel)
+	snippet      *Source
+	snippetStart int
+	// Current file name, changed by line directive
+	fileName string
+	// Line directive to apply at next line
+	directive *lineDirective
+	// Tokens found but not yet returned (token and tokens of included file)
+	pending      []Token
+	pendingIndex int
+	// Error that stop lexer
+	err  error
+	done bool
+}
 
-	for charPosInGlobalText < lenOfText {
-		currentToken, isFound = searchToken(text[charPosInGlobalText:], tokensList)
+// NewScanner create scanner of text
+func NewScanner(text string, tokensList []TokenEntry, options LexerOptions) *Scanner {
+	return newScanner(text, tokensList, options, []string{options.FileName})
+}
 
-		if isFound {
-			debugLog("Lexer", "Token %+v found", currentToken)
+func newScanner(text string, tokensList []TokenEntry, options LexerOptions, includes []string) *Scanner {
+	text, bomLength := decodeText(text, options)
+	options.StartOffset += bomLength
+
+	s := &Scanner{
+		text:            text,
+		tokensList:      tokensList,
+		options:         options,
+		includes:        includes,
+		charPos:         1,
+		lineNumber:      1,
+		onlyBlankBefore: true,
+		nextInvalid:     len(text),
+		index:           newTokenIndex(tokensList),
+		source:          NewSource(text, options.Newlines),
+		fileName:        options.FileName,
+	}
 
-			if currentToken.IDValue == SkipToken {
-				infoLog("Lexer", "Skip token")
+	s.snippet = s.source
 
-				debugLog("Lexer", "Length of token: %d - Current position in original text: %d'", currentToken.Lenght, charPosInGlobalText)
-			} else {
-				debugLog("Lexer", "Add token in list")
+	if options.StartColumn > 1 {
+		s.charPos = options.StartColumn
+	}
 
-				currentToken.LineNumber = lineNumber
-				currentToken.StartPos = charPos
+	if options.StartLine > 1 {
+		s.lineNumber = options.StartLine
+	}
 
-				tokens = append(tokens, currentToken)
-			}
+	if options.ValidateUTF8 {
+		s.nextInvalid = findInvalidUTF8(text, 0)
+	}
 
-			tokenStart := charPosInGlobalText
-			tokenEnd := charPosInGlobalText + currentToken.Lenght
+	return s
+}
 
-			// count number of line to have right index of token
-			lineNumberInToken, lastLinePos := countLineEnd(text[tokenStart:tokenEnd])
+// Next return next token. ok is false at end of text or when lexer stop on
+// error (see Err()).
+func (s *Scanner) Next() (token Token, ok bool) {
+	for s.pendingIndex >= len(s.pending) {
+		if s.done {
+			return Token{}, false
+		}
 
-			if lineNumberInToken == 0 {
-				// No new lines
-				charPos += currentToken.Lenght
+		s.pending = s.pending[:0]
+		s.pendingIndex = 0
+		s.scan()
+	}
 
-				debugLog("Lexer", "New position in line %d", charPos)
-			} else {
-				lineNumber += lineNumberInToken
-				charPos = currentToken.Lenght - lastLinePos[1] + 1 // +1 cause human position start 1
+	token = s.pending[s.pendingIndex]
+	s.pendingIndex++
 
-				debugLog("Lexer", "Line number %d, position in line %d", lineNumber, charPos)
-			}
+	return token, true
+}
 
-			// Increment to end of token to continue search
-			charPosInGlobalText += currentToken.Lenght
-		} else {
-			indexOfChar := charPos - 1
-			errorCode := extractPartOfText(text[charPosInGlobalText-indexOfChar:], indexOfChar)
+// Read read tokens in buffer and return number of tokens read, 0 at end of
+// text or when lexer stop on error (see Err()).
+func (s *Scanner) Read(buffer []Token) int {
+	count := 0
 
-			errorLog("Lexer", "No token found at %d:%d!\n%s", lineNumber, charPos, errorCode)
+	for count < len(buffer) {
+		token, ok := s.Next()
 
-			return tokens, fmt.Errorf("invalid token found at %d:%d\n%s", lineNumber, charPos, errorCode)
+		if !ok {
+			break
 		}
+
+		buffer[count] = token
+		count++
 	}
 
-	return tokens, nil
+	return count
 }
 
-func extractPartOfText(text string, start int) string {
-	var partOfText string
-	var end int
+// Err return error that stop lexer, or LexerErrors if errors found with error
+// recovery. Must be called after end of text.
+func (s *Scanner) Err() error {
+	return s.err
+}
 
-	// FindStringIndex return a array [begin end]
-	pos := endLineRegex.FindStringIndex(text)
+// Append all tokens to tokens.
+func (s *Scanner) appendTokens(tokens []Token) ([]Token, error) {
+	for {
+		token, ok := s.Next()
 
-	if len(pos) == 0 {
-		end = len(text) - 1
-	} else {
-		end = pos[0]
+		if !ok {
+			return tokens, s.Err()
+		}
+
+		tokens = append(tokens, token)
 	}
+}
 
-	partOfText = text[0:end]
-	partOfText = strings.ReplaceAll(partOfText, "\t", " ")
+// Stop lexer with error (nil at end of text).
+func (s *Scanner) stop(err error) {
+	s.done = true
+	s.err = err
 
-	return partOfText + "\n" + strings.Repeat("_", start) + "^"
+	if err == nil && len(s.errs) > 0 {
+		s.err = s.errs
+	}
 }
 
-// Search a token an return if found.
-func searchToken(text string, tokensList []TokenEntry) (Token, bool) {
-	currentToken := Token{}
-	isFound := false
+// Search next token and add it (and tokens of included file) in pending
+// tokens.
+func (s *Scanner) scan() {
+	if s.charPosInGlobalText >= len(s.text) {
+		s.stop(nil)
+		return
+	}
 
-	for _, token := range tokensList {
-		debugLog("searchToken", "Current token %+v", token)
+	text := s.text
+	options := &s.options
 
-		switch token.TypeOf {
-		case HardValue:
-			currentToken, isFound = tokenHardValue(text, token)
-		case RegexValue:
-			currentToken, isFound = tokenRegexValue(text, token)
-		default:
-			debugLog("searchToken", "Call user search method")
-			currentToken, isFound = token.FnCallback(text, token)
+	var currentToken Token
+	var entry *TokenEntry
+	var err error
+
+	message := "invalid token found"
+
+	if s.nextInvalid < s.charPosInGlobalText {
+		// Invalid sequence skip by error recovery
+		s.nextInvalid = findInvalidUTF8(text, s.charPosInGlobalText)
+	}
+
+	if s.charPosInGlobalText == s.nextInvalid {
+		message = "invalid UTF-8 sequence"
+		s.nextInvalid = findInvalidUTF8(text, s.charPosInGlobalText+1)
+	} else {
+		// Token can't contain invalid UTF-8 sequence
+		currentToken, entry, err = searchToken(text[s.charPosInGlobalText:s.nextInvalid], s.tokensList, s.index, s.charPos, s.onlyBlankBefore, options)
+	}
+
+	if err != nil || entry == nil {
+		if err != nil {
+			message = err.Error()
 		}
 
-		if isFound {
-			break
+		lexError := options.newLexError(s.snippet, s.snippetStart, message, s.fileName, s.lineNumber, s.charPos, s.charPosInGlobalText, RecoverySkipRune(text[s.charPosInGlobalText:]))
+		lexError.RulesTried = rulesTried(s.tokensList, s.charPos, s.onlyBlankBefore)
+
+		options.errorLog("Lexer", "%s", lexError)
+
+		if err != nil || !options.ErrorRecovery {
+			s.stop(lexError)
+			return
 		}
-	}
 
-	debugLog("searchToken", "Token return %+v", currentToken)
+		s.errs = append(s.errs, lexError)
+		currentToken = recoverToken(text[s.charPosInGlobalText:], *options)
+	}
 
-	return currentToken, isFound
-}
+	if entry != nil && options.SuggestDistance > 0 {
+		currentToken.Suggestion = entry.suggestKeyword(currentToken, options.SuggestDistance)
+	}
 
-// Check if token with hard value found.
-func tokenHardValue(text string, token TokenEntry) (Token, bool) {
-	lenOfSearch := len(token.Value)
+	if options.isLogEnabled(LexerLogDebug) {
+		options.debugLog("Lexer", "Token %+v found", currentToken)
+	}
 
-	debugLog("tokenHardValue", "Search hard value '%s'", token.Value)
+	currentToken.LineNumber = s.lineNumber
+	currentToken.StartPos = s.charPos
+	currentToken.Offset = options.StartOffset + s.charPosInGlobalText
+	currentToken.File = s.fileName
 
-	if len(text) >= lenOfSearch && text[:lenOfSearch] == token.Value {
-		return Token{
-			Name:    token.Name,
-			IDValue: token.IDValue,
-			Lenght:  lenOfSearch,
-			Data:    token.Value,
-		}, true
+	if entry != nil && options.LineDirective != "" && entry.Name == options.LineDirective {
+		s.directive = options.parseLineDirective(currentToken.Data)
 	}
 
-	return Token{}, false
-}
+	// Include token, before update of position
+	includeToken := currentToken
 
-// Check if token with regex value found.
-func tokenRegexValue(text string, token TokenEntry) (Token, bool) {
-	debugLog("tokenRegexValue", "Search regex value '%s'", token.Value)
+	tokenStart := s.charPosInGlobalText
+	tokenEnd := s.charPosInGlobalText + currentToken.Lenght
 
-	// FindStringIndex return a array [begin end]
-	pos := token.FindStringIndex(text)
+	// count number of line to have right index of token
+	lineNumberInToken, lastLineStart := s.source.countLineEnds(&s.lineCursor, tokenStart, tokenEnd)
 
-	debugLog("tokenRegexValue", "Regex result %+v", pos)
+	if lineNumberInToken == 0 {
+		// No new lines
+		s.charPos = options.advanceColumn(s.charPos, text[lastLineStart:tokenEnd])
+		s.onlyBlankBefore = s.onlyBlankBefore && isBlank(text[lastLineStart:tokenEnd])
 
-	if len(pos) == 0 {
-		return Token{}, false
+		if options.isLogEnabled(LexerLogDebug) {
+			options.debugLog("Lexer", "New position in line %d", s.charPos)
+		}
+	} else if s.directive != nil {
+		s.lineNumber = s.directive.line + lineNumberInToken - 1
+		s.charPos = options.advanceColumn(1, text[lastLineStart:tokenEnd])
+		s.onlyBlankBefore = isBlank(text[lastLineStart:tokenEnd])
+
+		if s.directive.file != "" {
+			s.fileName = s.directive.file
+		}
+
+		s.directive = nil
+
+		if options.isLogEnabled(LexerLogDebug) {
+			options.debugLog("Lexer", "Line directive, file %s, line number %d, position in line %d", s.fileName, s.lineNumber, s.charPos)
+		}
+	} else {
+		s.lineNumber += lineNumberInToken
+		s.charPos = options.advanceColumn(1, text[lastLineStart:tokenEnd]) // 1 cause human position start 1
+		s.onlyBlankBefore = isBlank(text[lastLineStart:tokenEnd])
+
+		if options.isLogEnabled(LexerLogDebug) {
+			options.debugLog("Lexer", "Line number %d, position in line %d", s.lineNumber, s.charPos)
+		}
 	}
 
-	value := text[:pos[1]]
+	// Increment to end of token to continue search
+	s.charPosInGlobalText += currentToken.Lenght
 
-	if token.FnCallback != nil {
-		debugLog("tokenSubPatternValue", "Call user search method")
-		return token.FnCallback(value, token)
+	if currentToken.IDValue == SkipToken {
+		options.infoLog("Lexer", "Skip token")
+
+		if options.isLogEnabled(LexerLogDebug) {
+			options.debugLog("Lexer", "Length of token: %d - Current position in original text: %d'", currentToken.Lenght, s.charPosInGlobalText)
+		}
+	} else {
+		options.debugLog("Lexer", "Add token in list")
+
+		currentToken.EndLine = s.lineNumber
+		currentToken.EndColumn = s.charPos
+
+		if options.NormalizeNewlines {
+			currentToken.Data = normalizeNewlines(currentToken.Data, options.Newlines)
+		}
+
+		s.pending = append(s.pending, currentToken)
 	}
 
-	if token.SubValue != nil {
-		for _, subValue := range token.SubValue {
-			if subValue.Value == value {
-				return Token{
-					Name:    subValue.Name,
-					IDValue: subValue.IDValue,
-					Lenght:  pos[1],
-					Data:    value,
-				}, true
+	if entry != nil && options.Include != "" && entry.Name == options.Include {
+		includeTokens, err := options.lexInclude(includeToken, s.tokensList, s.includes)
+		s.pending = append(s.pending, includeTokens...)
+
+		if includeErrs, ok := err.(LexerErrors); ok {
+			s.errs = append(s.errs, includeErrs...)
+		} else if includeError, ok := err.(*includeError); ok {
+			lexError := options.newLexError(s.snippet, s.snippetStart, includeError.message, s.fileName, includeToken.LineNumber, includeToken.StartPos, includeToken.Offset-options.StartOffset, includeToken.Lenght)
+
+			options.errorLog("Lexer", "%s", lexError)
+
+			if !options.ErrorRecovery {
+				s.stop(lexError)
+				return
 			}
+
+			s.errs = append(s.errs, lexError)
+		} else if err != nil {
+			s.stop(err)
+			return
 		}
 	}
 
-	return Token{
-		Name:    token.Name,
-		IDValue: token.IDValue,
-		Lenght:  pos[1],
-		Data:    value,
-	}, true
-}
+	if options.MaxErrors > 0 && len(s.errs) >= options.MaxErrors {
+		options.errorLog("Lexer", "Too many errors, stop")
 
-func errorLog(methodName, format string, a ...interface{}) {
-	if LexerLogLevel >= LexerLogError {
-		fmt.Printf("[ERROR] %s(): %s\n", methodName, fmt.Sprintf(format, a...))
+		s.stop(nil)
 	}
 }
 
-func infoLog(methodName, format string, a ...interface{}) {
-	if LexerLogLevel >= LexerLogInfo {
-		fmt.Printf("[INFO] %s(): %s\n", methodName, fmt.Sprintf(format, a...))
+// FileTokens is result of lexer of a file (see LexFiles)
+type FileTokens struct {
+	// Path of file
+	Path string
+	// Tokens found in file, until error
+	Tokens []Token
+	// Err is error to read or lex file, or error of context if file not lexed
+	Err error
+}
+
+// LexFiles read and lex files concurrently, with LexerOptions.Workers
+// goroutines. Files are read in fsys (e.g. paths from fs.Glob()), or in file
+// system of operating system if fsys is nil. Results are in same order than
+// paths. When ctx is cancelled, files not yet lexed have error of ctx, which
+// is returned.
+// Function calls in tokens list must be safe for concurrent use.
+func LexFiles(ctx context.Context, fsys fs.FS, paths []string, tokensList []TokenEntry, options LexerOptions) ([]FileTokens, error) {
+	results := make([]FileTokens, len(paths))
+	indexes := make(chan int)
+	workers := options.workers()
+
+	var wait sync.WaitGroup
+
+	for worker := 0; worker < workers; worker++ {
+		wait.Add(1)
+
+		go func() {
+			defer wait.Done()
+
+			for index := range indexes {
+				results[index] = lexFile(ctx, fsys, paths[index], tokensList, options)
+			}
+		}()
 	}
+
+	for index, name := range paths {
+		select {
+		case indexes <- index:
+		case <-ctx.Done():
+			results[index] = FileTokens{Path: name, Err: ctx.Err()}
+		}
+	}
+
+	close(indexes)
+	wait.Wait()
+
+	return results, ctx.Err()
 }
 
-func debugLog(methodName, format string, a ...interface{}) {
-	if LexerLogLevel >= LexerLogDebug {
-		fmt.Printf("[DEBUG] %s(): %s\n", methodName, fmt.Sprintf(format, a...))
+// Number of goroutines to use, GOMAXPROCS by default.
+func (o *LexerOptions) workers() int {
+	if o.Workers > 0 {
+		return o.Workers
 	}
+
+	return runtime.GOMAXPROCS(0)
 }
 
-func countLineEnd(str string) (int, []int) {
-	pos := endLineRegex.FindAllStringIndex(str, -1)
+// Read and lex a file. Context is checked every fileContextCheck tokens.
+func lexFile(ctx context.Context, fsys fs.FS, name string, tokensList []TokenEntry, options LexerOptions) FileTokens {
+	result := FileTokens{Path: name}
+
+	if result.Err = ctx.Err(); result.Err != nil {
+		return result
+	}
+
+	var content []byte
+
+	if fsys == nil {
+		content, result.Err = os.ReadFile(name)
+	} else {
+		content, result.Err = fs.ReadFile(fsys, name)
+
+		if options.IncludeFS == nil {
+			options.IncludeFS = fsys
+		}
+	}
+
+	if result.Err != nil {
+		return result
+	}
+
+	options.infoLog("LexFiles", "Lex file %s", name)
+
+	options.FileName = name
+	scanner := NewScanner(string(content), tokensList, options)
+	result.Tokens = []Token{}
+
+	for count := 1; ; count++ {
+		token, ok := scanner.Next()
+
+		if !ok {
+			result.Err = scanner.Err()
 
-	debugLog("countLineEnd", "Return line found in token %+v", pos)
+			return result
+		}
+
+		result.Tokens = append(result.Tokens, token)
+
+		if count%fileContextCheck == 0 && ctx.Err() != nil {
+			result.Err = ctx.Err()
+
+			return result
+		}
+	}
+}
 
-	l := len(pos)
+// Number of tokens between two checks of context in LexFiles
+const fileContextCheck = 1024
+
+// LexerParallel split text at end of lines ('\n') in parts of at least
+// LexerOptions.ChunkSize bytes, lex parts concurrently with
+// LexerOptions.Workers goroutines and return same tokens and errors than
+// LexerWithOptions(). Return an error if a token can continue after end of
+// line (see CheckSplitLines), with line directives or if '\n' is not an end of
+// line.
+func LexerParallel(text string, tokensList []TokenEntry, options LexerOptions) ([]Token, error) {
+	if err := CheckSplitLines(tokensList); err != nil {
+		return nil, err
+	}
 
-	if l == 0 {
-		return 0, []int{0, 0}
+	if options.LineDirective != "" {
+		return nil, fmt.Errorf("text with line directives can't be split")
+	}
+
+	if options.Newlines&NewlineLF == 0 {
+		return nil, fmt.Errorf("text can only be split at '\\n' end of lines")
+	}
+
+	text, bomLength := decodeText(text, options)
+	options.StartOffset += bomLength
+	options.Encoding = EncodingUTF8
+	options.StripBOM = false
+
+	starts := chunkStarts(text, options.chunkSize())
+	source := NewSource(text, options.Newlines)
+	chunkTokens := make([][]Token, len(starts))
+	chunkErrors := make([]error, len(starts))
+	indexes := make(chan int)
+
+	var wait sync.WaitGroup
+
+	for worker := 0; worker < min2(options.workers(), len(starts)); worker++ {
+		wait.Add(1)
+
+		go func() {
+			defer wait.Done()
+
+			for index := range indexes {
+				chunkTokens[index], chunkErrors[index] = lexChunk(text, starts, index, source, tokensList, options)
+			}
+		}()
+	}
+
+	for index := range starts {
+		indexes <- index
+	}
+
+	close(indexes)
+	wait.Wait()
+
+	tokens := []Token{}
+	errs := LexerErrors{}
+
+	for index := range starts {
+		chunkErrs, isErrs := chunkErrors[index].(LexerErrors)
+
+		if options.MaxErrors > 0 && len(errs)+len(chunkErrs) >= options.MaxErrors {
+			// Lex part again to stop at same error than LexerWithOptions()
+			chunkOptions := options
+			chunkOptions.MaxErrors = options.MaxErrors - len(errs)
+			chunkTokens[index], chunkErrors[index] = lexChunk(text, starts, index, source, tokensList, chunkOptions)
+			chunkErrs, isErrs = chunkErrors[index].(LexerErrors)
+		}
+
+		tokens = append(tokens, chunkTokens[index]...)
+
+		if chunkErrors[index] != nil && !isErrs {
+			return tokens, chunkErrors[index]
+		}
+
+		errs = append(errs, chunkErrs...)
+
+		if options.MaxErrors > 0 && len(errs) >= options.MaxErrors {
+			break
+		}
+	}
+
+	if len(errs) > 0 {
+		return tokens, errs
+	}
+
+	return tokens, nil
+}
+
+// Lex part index of text, with position of part in text.
+func lexChunk(text string, starts []int, index int, source *Source, tokensList []TokenEntry, options LexerOptions) ([]Token, error) {
+	start := starts[index]
+	end := len(text)
+
+	if index+1 < len(starts) {
+		end = starts[index+1]
+	}
+
+	if index > 0 {
+		line, _ := source.Position(start)
+
+		if options.StartLine > 1 {
+			line += options.StartLine - 1
+		}
+
+		options.StartLine = line
+		options.StartColumn = 1
+		options.StartOffset += start
+	}
+
+	// Render errors with lines of other parts
+	scanner := NewScanner(text[start:end], tokensList, options)
+	scanner.snippet = source
+	scanner.snippetStart = start
+
+	return scanner.appendTokens([]Token{})
+}
+
+// Number of bytes of parts of LexerParallel, 1 MiB by default.
+func (o *LexerOptions) chunkSize() int {
+	if o.ChunkSize > 0 {
+		return o.ChunkSize
+	}
+
+	return 1 << 20
+}
+
+// Return start of parts of text of at least size bytes, split after '\n'.
+func chunkStarts(text string, size int) []int {
+	starts := []int{0}
+
+	for {
+		last := starts[len(starts)-1]
+
+		if len(text)-last <= size {
+			return starts
+		}
+
+		pos := strings.IndexByte(text[last+size-1:], '\n')
+
+		if pos < 0 || last+size+pos >= len(text) {
+			return starts
+		}
+
+		starts = append(starts, last+size+pos)
+	}
+}
+
+// CheckSplitLines return an error if a token can continue after end of line
+// ('\n'), so text can't be split at end of lines (see LexerParallel). Function
+// calls must have FlagSingleLine.
+func CheckSplitLines(tokensList []TokenEntry) error {
+	for index := range tokensList {
+		if !tokensList[index].isSingleLine() {
+			return fmt.Errorf("rule '%s' can continue after end of line, text can't be split", tokensList[index].Name)
+		}
+	}
+
+	return nil
+}
+
+// Return true if token never continue after '\n'.
+func (t *TokenEntry) isSingleLine() bool {
+	if t.Flags&FlagSingleLine != 0 {
+		return true
+	}
+
+	switch t.TypeOf {
+	case HardValue:
+		pos := strings.IndexByte(t.Value, '\n')
+
+		return pos < 0 || pos == len(t.Value)-1
+	case RegexValue:
+		re, err := syntax.Parse(regexFlags(t.Flags)+t.Value, syntax.Perl)
+
+		if err != nil {
+			return false
+		}
+
+		prog, err := syntax.Compile(re.Simplify())
+
+		if err != nil {
+			return false
+		}
+
+		for pc := range prog.Inst {
+			if matchRune(&prog.Inst[pc], '\n') && continueAfter(prog, prog.Inst[pc].Out, map[uint32]bool{}) {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	return false
+}
+
+// Return true if program can read a character or check position (^, $, \b...)
+// from pc.
+func continueAfter(prog *syntax.Prog, pc uint32, visited map[uint32]bool) bool {
+	if visited[pc] {
+		return false
+	}
+
+	visited[pc] = true
+	inst := &prog.Inst[pc]
+
+	switch inst.Op {
+	case syntax.InstMatch, syntax.InstFail:
+		return false
+	case syntax.InstAlt, syntax.InstAltMatch:
+		return continueAfter(prog, inst.Out, visited) || continueAfter(prog, inst.Arg, visited)
+	case syntax.InstCapture, syntax.InstNop:
+		return continueAfter(prog, inst.Out, visited)
+	}
+
+	return true
+}
+
+// Create error at offset (in text) and render line of text with source, where
+// text start at sourceStart.
+func (o *LexerOptions) newLexError(source *Source, sourceStart int, message string, file string, line int, column int, offset int, length int) *LexError {
+	lexError := &LexError{
+		Message: message,
+		File:    file,
+		Line:    line,
+		Column:  column,
+		Offset:  o.StartOffset + offset,
+		Length:  length,
+	}
+
+	diagnosticOptions := o.Diagnostic
+	diagnosticOptions.Newlines = o.Newlines
+	diagnosticOptions.StartOffset = o.StartOffset - sourceStart
+
+	lexError.Snippet = renderSnippet(source, lexError.Diagnostic(), diagnosticOptions)
+
+	return lexError
+}
+
+// Error of include directive, converted in LexError at position of include
+type includeError struct {
+	message string
+}
+
+func (e *includeError) Error() string {
+	return e.message
+}
+
+// Read and lex file of include token.
+func (o *LexerOptions) lexInclude(token Token, tokensList []TokenEntry, includes []string) ([]Token, error) {
+	if o.IncludeParser == nil || o.IncludeFS == nil {
+		return nil, &includeError{"include not supported"}
+	}
+
+	name, ok := o.IncludeParser(token.Data)
+
+	if !ok {
+		return nil, &includeError{fmt.Sprintf("invalid include '%s'", token.Data)}
+	}
+
+	if strings.HasPrefix(name, "/") {
+		name = path.Clean(name[1:])
+	} else {
+		name = path.Join(path.Dir(includes[len(includes)-1]), name)
+	}
+
+	for _, file := range includes {
+		if file == name {
+			return nil, &includeError{fmt.Sprintf("include cycle: %s -> %s", strings.Join(includes, " -> "), name)}
+		}
+	}
+
+	if len(includes) > o.MaxIncludeDepth {
+		return nil, &includeError{fmt.Sprintf("too many nested includes (max %d)", o.MaxIncludeDepth)}
+	}
+
+	content, err := fs.ReadFile(o.IncludeFS, name)
+
+	if err != nil {
+		return nil, &includeError{fmt.Sprintf("cannot include '%s': %s", name, err)}
+	}
+
+	o.infoLog("Lexer", "Include file %s", name)
+
+	options := *o
+	options.FileName = name
+	options.StartLine = 1
+	options.StartColumn = 1
+	options.StartOffset = 0
+
+	return lexText(string(content), tokensList, options, append(includes[:len(includes):len(includes)], name))
+}
+
+// ParseInclude read path between quotes (include "other.conf") or angle
+// brackets (#include <other.h>)
+func ParseInclude(data string) (string, bool) {
+	if pos := strings.IndexByte(data, '"'); pos != -1 {
+		return unquotePrefix(data[pos:])
+	}
+
+	start := strings.IndexByte(data, '<')
+	end := strings.LastIndexByte(data, '>')
+
+	if start == -1 || end < start {
+		return "", false
+	}
+
+	return data[start+1 : end], true
+}
+
+// Line directive found, apply at next line
+type lineDirective struct {
+	file string
+	line int
+}
+
+// Read line directive with parser. Return nil if directive is invalid.
+func (o *LexerOptions) parseLineDirective(data string) *lineDirective {
+	if o.LineDirectiveParser == nil {
+		return nil
+	}
+
+	file, line, ok := o.LineDirectiveParser(data)
+
+	if !ok {
+		o.errorLog("Lexer", "Invalid line directive '%s'", data)
+
+		return nil
+	}
+
+	return &lineDirective{file: file, line: line}
+}
+
+// ParseLineDirective read C line directive (#line 42 "file" or # 42 "file")
+// and Go line directive (//line file:42 or //line file:42:5)
+func ParseLineDirective(data string) (string, int, bool) {
+	data = strings.TrimSpace(data)
+
+	if strings.HasPrefix(data, "//line ") {
+		directive := strings.TrimSpace(data[len("//line "):])
+		pos := strings.LastIndexByte(directive, ':')
+
+		if pos == -1 {
+			return "", 0, false
+		}
+
+		line, err := strconv.Atoi(directive[pos+1:])
+
+		if err != nil {
+			return "", 0, false
+		}
+
+		// file:line:column
+		if column := strings.LastIndexByte(directive[:pos], ':'); column != -1 {
+			if columnLine, err := strconv.Atoi(directive[column+1 : pos]); err == nil {
+				return directive[:column], columnLine, true
+			}
+		}
+
+		return directive[:pos], line, true
+	}
+
+	if !strings.HasPrefix(data, "#") {
+		return "", 0, false
+	}
+
+	directive := strings.TrimSpace(data[1:])
+	directive = strings.TrimSpace(strings.TrimPrefix(directive, "line"))
+
+	fields := strings.SplitN(directive, " ", 2)
+	line, err := strconv.Atoi(fields[0])
+
+	if err != nil {
+		return "", 0, false
+	}
+
+	if len(fields) == 1 {
+		return "", line, true
+	}
+
+	file, ok := unquotePrefix(strings.TrimSpace(fields[1]))
+
+	if !ok {
+		return "", 0, false
+	}
+
+	return file, line, true
+}
+
+// Unquote string at start of text, ignore text after string (e.g. flags of
+// GCC line markers).
+func unquotePrefix(text string) (string, bool) {
+	if !strings.HasPrefix(text, "\"") {
+		return "", false
+	}
+
+	for end := 1; end < len(text); end++ {
+		if text[end] == '\\' {
+			end++
+		} else if text[end] == '"' {
+			file, err := strconv.Unquote(text[:end+1])
+
+			return file, err == nil
+		}
+	}
+
+	return "", false
+}
+
+// Convert text in UTF-8 and remove byte order mark. Return also length of byte
+// order mark removed, to keep offsets of original text.
+func decodeText(text string, options LexerOptions) (string, int) {
+	encoding := options.Encoding
+
+	if encoding == EncodingAuto {
+		switch {
+		case strings.HasPrefix(text, bomUTF16LE):
+			encoding = EncodingUTF16LE
+		case strings.HasPrefix(text, bomUTF16BE):
+			encoding = EncodingUTF16BE
+		default:
+			encoding = EncodingUTF8
+		}
+	}
+
+	if encoding == EncodingUTF16LE || encoding == EncodingUTF16BE {
+		text = decodeUTF16(text, encoding == EncodingUTF16BE)
+	}
+
+	if options.StripBOM && strings.HasPrefix(text, bomUTF8) {
+		return text[len(bomUTF8):], len(bomUTF8)
+	}
+
+	return text, 0
+}
+
+// Convert UTF-16 text in UTF-8. Invalid sequences are replaced by U+FFFD.
+func decodeUTF16(text string, bigEndian bool) string {
+	units := make([]uint16, 0, len(text)/2)
+
+	for index := 0; index+1 < len(text); index += 2 {
+		if bigEndian {
+			units = append(units, uint16(text[index])<<8|uint16(text[index+1]))
+		} else {
+			units = append(units, uint16(text[index+1])<<8|uint16(text[index]))
+		}
+	}
+
+	result := string(utf16.Decode(units))
+
+	if len(text)%2 != 0 {
+		result += string(utf8.RuneError)
+	}
+
+	return result
+}
+
+// Return position of first invalid UTF-8 sequence in text after start, or
+// length of text if not found.
+func findInvalidUTF8(text string, start int) int {
+	for index := start; index < len(text); {
+		char, size := utf8.DecodeRuneInString(text[index:])
+
+		if char == utf8.RuneError && size == 1 {
+			return index
+		}
+
+		index += size
+	}
+
+	return len(text)
+}
+
+// Return column after str that start at column, in unit of column.
+func (o *LexerOptions) advanceColumn(column int, str string) int {
+	switch o.ColumnUnit {
+	case ColumnRunes:
+		return column + utf8.RuneCountInString(str)
+	case ColumnUTF16:
+		for _, char := range str {
+			if char >= 0x10000 {
+				column += 2
+			} else {
+				column++
+			}
+		}
+
+		return column
+	case ColumnDisplay:
+		return column + displayWidth(str, column-1, o.TabWidth)
+	}
+
+	return column + len(str)
+}
+
+// Create error token with invalid input skip by recovery strategy.
+func recoverToken(text string, options LexerOptions) Token {
+	lenght := 0
+
+	if options.Recovery != nil {
+		lenght = options.Recovery(text)
+	}
+
+	if lenght <= 0 {
+		// Always skip at least one character to avoid infinite loop
+		lenght = RecoverySkipRune(text)
+	}
+
+	if lenght > len(text) {
+		lenght = len(text)
+	}
+
+	return Token{
+		Name:    ErrorTokenName,
+		IDValue: options.ErrorIDValue,
+		Lenght:  lenght,
+		Data:    text[:lenght],
+	}
+}
+
+// Diagnostic is a message about a part of text
+type Diagnostic struct {
+	// Message to display
+	Message string
+	// File name of text
+	File string
+	// Line is line number display
+	Line int
+	// Column is column display
+	Column int
+	// Offset is position in bytes of part of text
+	Offset int
+	// Length is length in bytes of part of text
+	Length int
+}
+
+// DiagnosticOptions options to render a diagnostic
+type DiagnosticOptions struct {
+	// ContextBefore number of lines display before line of diagnostic
+	ContextBefore int
+	// ContextAfter number of lines display after line of diagnostic
+	ContextAfter int
+	// TabWidth number of columns of a tab
+	TabWidth int
+	// Color if true, use ANSI color
+	Color bool
+	// Newlines is end of lines to split text in lines (NewlineLF...)
+	Newlines int
+	// StartOffset is position in bytes of start of text in file, when text
+	// is a part of a file (see LexerOptions.StartOffset). Offset of
+	// diagnostic is position in file.
+	StartOffset int
+}
+
+// ANSI escape code to colorize diagnostic
+const (
+	colorBold  = "\x1b[1m"
+	colorRed   = "\x1b[31;1m"
+	colorBlue  = "\x1b[34m"
+	colorReset = "\x1b[0m"
+)
+
+// NewDiagnosticOptions create default options of diagnostic
+func NewDiagnosticOptions() DiagnosticOptions {
+	return DiagnosticOptions{
+		ContextBefore: 0,
+		ContextAfter:  0,
+		TabWidth:      4,
+		Color:         false,
+		Newlines:      NewlineDefault,
+		StartOffset:   0,
+	}
+}
+
+// DetectColor return true if file is a terminal that support color.
+// NO_COLOR environment variable disable color.
+func DetectColor(f *os.File) bool {
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+
+	info, err := f.Stat()
+
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// NewTokenDiagnostic create a diagnostic about a token found in text
+func NewTokenDiagnostic(token Token, message string) Diagnostic {
+	return Diagnostic{
+		Message: message,
+		File:    token.File,
+		Line:    token.LineNumber,
+		Column:  token.StartPos,
+		Offset:  token.Offset,
+		Length:  token.Lenght,
+	}
+}
+
+// Header of diagnostic: file:line:col: message
+func (d Diagnostic) header() string {
+	position := fmt.Sprintf("%d:%d", d.Line, d.Column)
+
+	if d.File != "" {
+		position = d.File + ":" + position
+	}
+
+	return position + ": " + d.Message
+}
+
+// RenderDiagnostic render diagnostic with line of text, context and a marker
+// under part of text
+func RenderDiagnostic(text string, diagnostic Diagnostic, options DiagnosticOptions) string {
+	header := diagnostic.header()
+
+	if options.Color {
+		header = colorBold + header + colorReset
+	}
+
+	return header + "\n" + renderSnippet(NewSource(text, options.Newlines), diagnostic, options)
+}
+
+// Render lines of text around diagnostic, with marker under part of text.
+// Source must be created with options.Newlines.
+func renderSnippet(source *Source, diagnostic Diagnostic, options DiagnosticOptions) string {
+	text := source.Text()
+	// Offset in text
+	offset := clamp(diagnostic.Offset-options.StartOffset, 0, len(text))
+	currentLine, _ := source.Position(offset)
+	// Index of line
+	currentLine--
+
+	first := currentLine - options.ContextBefore
+	last := currentLine + options.ContextAfter
+
+	if first < 0 {
+		first = 0
+	}
+
+	if last >= source.LineCount() {
+		last = source.LineCount() - 1
+	}
+
+	// Line number display is relative to line of diagnostic
+	gutterWidth := len(fmt.Sprintf("%d", diagnostic.Line+last-currentLine))
+	result := []string{}
+
+	for index := first; index <= last; index++ {
+		lineStart, lineEnd := source.lineBounds(index)
+		line := text[lineStart:lineEnd]
+		gutter := fmt.Sprintf("%*d | ", gutterWidth, diagnostic.Line+index-currentLine)
+
+		if options.Color {
+			gutter = colorBlue + gutter + colorReset
+		}
+
+		result = append(result, gutter+expandTabs(line, options.TabWidth))
+
+		if index == currentLine {
+			result = append(result, renderMarker(line, offset-lineStart, diagnostic.Length, gutterWidth, options))
+		}
+	}
+
+	return strings.Join(result, "\n")
+}
+
+// Render marker line under part of line that start at start and has length
+// bytes.
+func renderMarker(line string, start int, length int, gutterWidth int, options DiagnosticOptions) string {
+	if start > len(line) {
+		start = len(line)
+	}
+
+	end := start + length
+
+	if end > len(line) {
+		end = len(line)
+	}
+
+	startColumn := displayWidth(line[:start], 0, options.TabWidth)
+	markerWidth := displayWidth(line[start:end], startColumn, options.TabWidth)
+
+	if markerWidth < 1 {
+		markerWidth = 1
+	}
+
+	gutter := strings.Repeat(" ", gutterWidth) + " | "
+	marker := strings.Repeat("^", markerWidth)
+
+	if options.Color {
+		gutter = colorBlue + gutter + colorReset
+		marker = colorRed + marker + colorReset
+	}
+
+	return gutter + strings.Repeat(" ", startColumn) + marker
+}
+
+// Replace tabs by spaces until next tab stop.
+func expandTabs(line string, tabWidth int) string {
+	if !strings.Contains(line, "\t") {
+		return line
+	}
+
+	var result strings.Builder
+	column := 0
+
+	for _, char := range line {
+		if char == '\t' {
+			spaces := tabStop(column, tabWidth) - column
+			result.WriteString(strings.Repeat(" ", spaces))
+			column += spaces
+		} else {
+			result.WriteRune(char)
+			column += runeWidth(char)
+		}
+	}
+
+	return result.String()
+}
+
+// Return number of columns to display str that start at column.
+func displayWidth(str string, column int, tabWidth int) int {
+	start := column
+
+	for _, char := range str {
+		if char == '\t' {
+			column = tabStop(column, tabWidth)
+		} else {
+			column += runeWidth(char)
+		}
+	}
+
+	return column - start
+}
+
+// Return next tab stop after column.
+func tabStop(column int, tabWidth int) int {
+	if tabWidth <= 0 {
+		return column + 1
+	}
+
+	return (column/tabWidth + 1) * tabWidth
+}
+
+// Wide characters (East Asian and emoji) display in two columns
+var wideRunes = [][]rune{
+	{0x1100, 0x115F}, {0x2E80, 0x303E}, {0x3041, 0x33FF}, {0x3400, 0x4DBF},
+	{0x4E00, 0x9FFF}, {0xA000, 0xA4CF}, {0xAC00, 0xD7A3}, {0xF900, 0xFAFF},
+	{0xFE30, 0xFE4F}, {0xFF00, 0xFF60}, {0xFFE0, 0xFFE6}, {0x1F300, 0x1F64F},
+	{0x1F900, 0x1F9FF}, {0x20000, 0x3FFFD},
+}
+
+// Return number of columns to display a character.
+func runeWidth(char rune) int {
+	if unicode.Is(unicode.Mn, char) || unicode.Is(unicode.Me, char) || char == 0x200B {
+		return 0
+	}
+
+	for _, wide := range wideRunes {
+		if char >= wide[0] && char <= wide[1] {
+			return 2
+		}
+	}
+
+	return 1
+}
+
+// Return name of rules tried at this position.
+func rulesTried(tokensList []TokenEntry, charPos int, onlyBlankBefore bool) []string {
+	names := []string{}
+
+	for index := range tokensList {
+		if tokensList[index].isAllowedAt(charPos, onlyBlankBefore) {
+			names = append(names, tokensList[index].Name)
+		}
+	}
+
+	return names
+}
+
+// Search a token an return it and token entry that found it (nil if not found).
+// charPos and onlyBlankBefore are used to check token anchored in line.
+// Return an error if a callback return a token with zero length, to avoid
+// infinite loop.
+func searchToken(text string, tokensList []TokenEntry, tokenIndex *tokenIndex, charPos int, onlyBlankBefore bool, options *LexerOptions) (Token, *TokenEntry, error) {
+	currentToken := Token{}
+	isFound := false
+	bestToken := Token{}
+	var bestEntry *TokenEntry
+	// Length of match of each token found by automaton
+	var lengths []int
+
+	if options.Automaton != nil {
+		lengths = options.Automaton.match(text, tokenIndex.lengths)
+		tokenIndex.lengths = lengths
+	}
+
+	// Hard values of trie are all found, other tokens must be checked
+	hardValues := tokenIndex.hardValues(text)
+
+	for _, index := range tokenIndex.merge(text, hardValues) {
+		token := &tokensList[index]
+
+		if options.isLogEnabled(LexerLogDebug) {
+			options.debugLog("searchToken", "Current token %+v", *token)
+		}
+
+		if !token.isAllowedAt(charPos, onlyBlankBefore) {
+			if options.isLogEnabled(LexerLogDebug) {
+				options.debugLog("searchToken", "Token not allowed at position %d of line", charPos)
+			}
+
+			continue
+		}
+
+		switch {
+		case tokenIndex.inTrie[index]:
+			currentToken, isFound = hardValueToken(text, len(token.Value), *token), true
+		case lengths != nil && options.Automaton.handle(index):
+			currentToken, isFound = tokenAutomatonValue(text, lengths[index], *token, tokenIndex.subValues[index])
+		case token.TypeOf == HardValue:
+			currentToken, isFound = tokenHardValue(text, *token)
+		case token.TypeOf == RegexValue:
+			currentToken, isFound = tokenRegexValue(text, *token, tokenIndex.regex(tokensList, index), tokenIndex.subValues[index])
+		default:
+			options.debugLog("searchToken", "Call user search method")
+			currentToken, isFound = token.FnCallback(text, *token)
+		}
+
+		if isFound {
+			if currentToken.Lenght == 0 {
+				return currentToken, nil, fmt.Errorf("zero-length token returned by rule '%s'", token.Name)
+			}
+
+			if options.isLogEnabled(LexerLogDebug) {
+				options.debugLog("searchToken", "Token return %+v", currentToken)
+			}
+
+			if !options.LongestMatch {
+				return currentToken, token, nil
+			}
+
+			if bestEntry == nil || currentToken.Lenght > bestToken.Lenght {
+				bestToken = currentToken
+				bestEntry = token
+			}
+		}
+	}
+
+	if bestEntry != nil {
+		return bestToken, bestEntry, nil
+	}
+
+	return currentToken, nil, nil
+}
+
+// Create token found by automaton. length is -1 if not found.
+func tokenAutomatonValue(text string, length int, token TokenEntry, subValues map[string]int) (Token, bool) {
+	if length < 0 {
+		return Token{}, false
+	}
+
+	if token.TypeOf == HardValue {
+		return hardValueToken(text, length, token), true
+	}
+
+	return regexMatchToken(text, length, token, subValues)
+}
+
+// Create token of hard value found with length bytes.
+func hardValueToken(text string, length int, token TokenEntry) Token {
+	return Token{
+		Name:    token.Name,
+		IDValue: token.IDValue,
+		Lenght:  length,
+		Data:    text[:length],
+	}
+}
+
+// Check if token with hard value found.
+func tokenHardValue(text string, token TokenEntry) (Token, bool) {
+	lenOfSearch := len(token.Value)
+
+	if len(text) >= lenOfSearch && token.equal(text[:lenOfSearch], token.Value) {
+		return hardValueToken(text, lenOfSearch, token), true
+	}
+
+	return Token{}, false
+}
+
+// Check if token with regex value found with re.
+func tokenRegexValue(text string, token TokenEntry, re *regexp.Regexp, subValues map[string]int) (Token, bool) {
+	// FindStringIndex return a array [begin end]
+	pos := re.FindStringIndex(text)
+
+	if len(pos) == 0 {
+		return Token{}, false
+	}
+
+	return regexMatchToken(text, pos[1], token, subValues)
+}
+
+// Create token of regex that match length bytes of text. subValues is index
+// of sub patterns by value (see newTokenIndex).
+func regexMatchToken(text string, length int, token TokenEntry, subValues map[string]int) (Token, bool) {
+	if length == 0 {
+		// Empty string found, try next token to avoid infinite loop
+		return Token{}, false
+	}
+
+	value := text[:length]
+
+	if token.FnCallback != nil {
+		return token.FnCallback(value, token)
+	}
+
+	if index, found := subValues[token.subValueKey(value)]; found {
+		subValue := token.SubValue[index]
+
+		return Token{
+			Name:    subValue.Name,
+			IDValue: subValue.IDValue,
+			Lenght:  length,
+			Data:    value,
+		}, true
+	}
+
+	return Token{
+		Name:    token.Name,
+		IDValue: token.IDValue,
+		Lenght:  length,
+		Data:    value,
+	}, true
+}
+
+// Index of tokens list to search tokens quickly
+type tokenIndex struct {
+	// Trie of hard values
+	trie *trieNode
+	// Trie of ASCII case-insensitive hard values, in lower case
+	foldTrie *trieNode
+	// inTrie is true for token in a trie
+	inTrie []bool
+	// Tokens not in a trie, in order
+	others []int
+	// Tokens not in a trie that can start with byte, in order. Built when
+	// needed.
+	othersByByte [256][]int
+	// First bytes of tokens, nil for function call
+	first []*byteSet
+	// Index of sub pattern by value (see subValueKey) of each token
+	subValues []map[string]int
+	// Regex of each token, compiled when needed if token entry is created
+	// without constructor
+	regexes []*regexp.Regexp
+	// Buffers reused at each search, to not allocate memory for each token
+	found   []int
+	merged  []int
+	lengths []int
+}
+
+// Set of bytes
+type byteSet [4]uint64
+
+func (b *byteSet) add(char byte) {
+	b[char/64] |= 1 << (char % 64)
+}
+
+func (b *byteSet) contains(char byte) bool {
+	return b[char/64]&(1<<(char%64)) != 0
+}
+
+// Add first bytes of UTF-8 encoding of characters from low to high. Invalid
+// UTF-8 sequence are read as utf8.RuneError.
+func (b *byteSet) addRunes(low rune, high rune) {
+	if high > unicode.MaxRune {
+		high = unicode.MaxRune
+	}
+
+	for char := low; char <= high && char < utf8.RuneSelf; char++ {
+		b.add(byte(char))
+	}
+
+	if high < utf8.RuneSelf {
+		return
+	}
+
+	if low < utf8.RuneSelf {
+		low = utf8.RuneSelf
+	}
+
+	// First byte of UTF-8 encoding increase with character
+	for char := int(firstByteOf(low)); char <= int(firstByteOf(high)); char++ {
+		b.add(byte(char))
+	}
+
+	if low <= utf8.RuneError && utf8.RuneError <= high {
+		for char := utf8.RuneSelf; char < 256; char++ {
+			b.add(byte(char))
+		}
+	}
+}
+
+// Return first byte of UTF-8 encoding of char.
+func firstByteOf(char rune) byte {
+	if char >= 0xD800 && char <= 0xDFFF {
+		// Surrogates can't be encoded, first byte is between first byte of
+		// characters before and after
+		return 0xED
+	}
+
+	var buffer [utf8.UTFMax]byte
+	utf8.EncodeRune(buffer[:], char)
+
+	return buffer[0]
+}
+
+// Return first bytes of text that hard value or regex can match. Return nil
+// if all bytes are possible.
+func firstBytes(typeOf int, value string, flags int) *byteSet {
+	set := &byteSet{}
+
+	if typeOf == HardValue {
+		if value == "" {
+			return nil
+		}
+
+		char, _ := utf8.DecodeRuneInString(value)
+		set.addRunes(char, char)
+
+		if flags&FlagCaseInsensitive != 0 {
+			for fold := unicode.SimpleFold(char); fold != char; fold = unicode.SimpleFold(fold) {
+				set.addRunes(fold, fold)
+			}
+		}
+
+		return set
+	}
+
+	re, err := syntax.Parse(regexFlags(flags)+value, syntax.Perl)
+
+	if err != nil {
+		return nil
+	}
+
+	// Regex that match empty string is not a token, so only non empty
+	// match are useful
+	addFirstRunes(set, re)
+
+	return set
+}
+
+// Add first characters that regex can match in set. Return true if regex can
+// match empty string.
+func addFirstRunes(set *byteSet, re *syntax.Regexp) bool {
+	switch re.Op {
+	case syntax.OpLiteral:
+		if len(re.Rune) == 0 {
+			return true
+		}
+
+		char := re.Rune[0]
+		set.addRunes(char, char)
+
+		if re.Flags&syntax.FoldCase != 0 {
+			for fold := unicode.SimpleFold(char); fold != char; fold = unicode.SimpleFold(fold) {
+				set.addRunes(fold, fold)
+			}
+		}
+
+		return false
+	case syntax.OpCharClass:
+		for index := 0; index+1 < len(re.Rune); index += 2 {
+			set.addRunes(re.Rune[index], re.Rune[index+1])
+		}
+
+		return false
+	case syntax.OpAnyCharNotNL:
+		set.addRunes(0, '\n'-1)
+		set.addRunes('\n'+1, unicode.MaxRune)
+
+		return false
+	case syntax.OpAnyChar:
+		set.addRunes(0, unicode.MaxRune)
+
+		return false
+	case syntax.OpNoMatch:
+		return false
+	case syntax.OpCapture:
+		return addFirstRunes(set, re.Sub[0])
+	case syntax.OpStar, syntax.OpQuest:
+		addFirstRunes(set, re.Sub[0])
+
+		return true
+	case syntax.OpPlus:
+		return addFirstRunes(set, re.Sub[0])
+	case syntax.OpRepeat:
+		return addFirstRunes(set, re.Sub[0]) || re.Min == 0
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			if !addFirstRunes(set, sub) {
+				return false
+			}
+		}
+
+		return true
+	case syntax.OpAlternate:
+		matchEmpty := false
+
+		for _, sub := range re.Sub {
+			if addFirstRunes(set, sub) {
+				matchEmpty = true
+			}
+		}
+
+		return matchEmpty
+	}
+
+	// Empty match and empty-width assertions (^, $, \b...)
+	return true
+}
+
+// Node of trie, one child by byte
+type trieNode struct {
+	children map[byte]*trieNode
+	// Tokens with value that end at this node, in order
+	tokens []int
+}
+
+// Create index of tokens list.
+func newTokenIndex(tokensList []TokenEntry) *tokenIndex {
+	index := &tokenIndex{
+		trie:      &trieNode{},
+		foldTrie:  &trieNode{},
+		inTrie:    make([]bool, len(tokensList)),
+		subValues: make([]map[string]int, len(tokensList)),
+		first:     make([]*byteSet, len(tokensList)),
+		regexes:   make([]*regexp.Regexp, len(tokensList)),
+	}
+
+	for position := range tokensList {
+		token := &tokensList[position]
+
+		switch {
+		case token.TypeOf == HardValue && token.Flags&FlagCaseInsensitive == 0:
+			index.trie.add(token.Value, position)
+			index.inTrie[position] = true
+		case token.TypeOf == HardValue && isASCII(token.Value):
+			index.foldTrie.add(strings.ToLower(token.Value), position)
+			index.inTrie[position] = true
+		default:
+			index.others = append(index.others, position)
+
+			if token.TypeOf != FunctionCall {
+				index.first[position] = token.first
+
+				if token.first == nil {
+					// Token entry created without constructor
+					index.first[position] = firstBytes(token.TypeOf, token.Value, token.Flags)
+				}
+			}
+		}
+
+		if token.TypeOf == RegexValue && token.SubValue != nil {
+			subValues := map[string]int{}
+
+			for subPosition := len(token.SubValue) - 1; subPosition >= 0; subPosition-- {
+				// First sub pattern win
+				subValues[token.subValueKey(token.SubValue[subPosition].Value)] = subPosition
+			}
+
+			index.subValues[position] = subValues
+		}
+	}
+
+	return index
+}
+
+// Return regex of token at index, compiled once by index if token entry is
+// created without constructor.
+func (i *tokenIndex) regex(tokensList []TokenEntry, index int) *regexp.Regexp {
+	if i.regexes[index] == nil {
+		i.regexes[index] = tokensList[index].regex()
+	}
+
+	return i.regexes[index]
+}
+
+// Key of value in index of sub patterns: value, or value with each character
+// replaced by smallest character with same case folding if case-insensitive.
+func (t *TokenEntry) subValueKey(value string) string {
+	if t.Flags&FlagCaseInsensitive == 0 {
+		return value
+	}
+
+	return strings.Map(func(char rune) rune {
+		smallest := char
+
+		for fold := unicode.SimpleFold(char); fold != char; fold = unicode.SimpleFold(fold) {
+			if fold < smallest {
+				smallest = fold
+			}
+		}
+
+		return smallest
+	}, value)
+}
+
+// Add value of token in trie.
+func (n *trieNode) add(value string, token int) {
+	for position := 0; position < len(value); position++ {
+		if n.children == nil {
+			n.children = map[byte]*trieNode{}
+		}
+
+		child, found := n.children[value[position]]
+
+		if !found {
+			child = &trieNode{}
+			n.children[value[position]] = child
+		}
+
+		n = child
+	}
+
+	n.tokens = append(n.tokens, token)
+}
+
+// Return hard value tokens found at start of text, in order.
+func (i *tokenIndex) hardValues(text string) []int {
+	found := i.found[:0]
+
+	for _, trie := range []*trieNode{i.trie, i.foldTrie} {
+		n := trie
+
+		for position := 0; n != nil; position++ {
+			found = append(found, n.tokens...)
+
+			if position >= len(text) {
+				break
+			}
+
+			char := text[position]
+
+			if trie == i.foldTrie && char >= 'A' && char <= 'Z' {
+				char += 'a' - 'A'
+			}
+
+			n = n.children[char]
+		}
+	}
+
+	sort.Ints(found)
+	i.found = found
+
+	return found
+}
+
+// Return tokens not in trie that can start with char, in order.
+func (i *tokenIndex) othersFor(char byte) []int {
+	if i.othersByByte[char] == nil {
+		others := []int{}
+
+		for _, position := range i.others {
+			if i.first[position] == nil || i.first[position].contains(char) {
+				others = append(others, position)
+			}
+		}
+
+		i.othersByByte[char] = others
+	}
+
+	return i.othersByByte[char]
+}
+
+// Return tokens to check at start of text: tokens found in trie and tokens not
+// in trie that can start with first byte of text, in order.
+func (i *tokenIndex) merge(text string, hardValues []int) []int {
+	others := i.others
+
+	if len(text) > 0 {
+		others = i.othersFor(text[0])
+	}
+
+	if len(hardValues) == 0 {
+		return others
+	}
+
+	result := i.merged[:0]
+
+	for len(others) > 0 || len(hardValues) > 0 {
+		if len(hardValues) == 0 || (len(others) > 0 && others[0] < hardValues[0]) {
+			result = append(result, others[0])
+			others = others[1:]
+		} else {
+			result = append(result, hardValues[0])
+			hardValues = hardValues[1:]
+		}
+	}
+
+	i.merged = result
+
+	return result
+}
+
+// Maximum number of states of automaton, cache is cleared when reached
+const automatonMaxStates = 10000
+
+// Automaton search hard values and regex of all tokens in one pass. States
+// are built when needed and kept in cache. Function calls and regex with
+// empty-width assertions (^, $, \b...) are searched one by one.
+// Automaton is safe for concurrent use: goroutines search in parallel, but
+// wait each other when a state is built. With many goroutines (e.g. LexFiles),
+// prefer automaton created with table, that never build state.
+type Automaton struct {
+	// Read lock to search, write lock to build a state
+	mutex sync.RWMutex
+	// Program of each token, nil if searched one by one
+	progs []*syntax.Prog
+	// First state
+	start *automatonState
+	// States by key
+	states map[string]*automatonState
+	// All states, if automaton created with table
+	table *AutomatonTable
+	// Next state of ASCII characters of each state of table, -1 if none
+	ascii [][utf8.RuneSelf]int32
+}
+
+// AutomatonTable is all states of automaton, to create automaton without
+// compile regex (see slex generate --backend=dfa)
+type AutomatonTable struct {
+	// Tokens is true for token searched by automaton
+	Tokens []bool
+	// Matches is tokens that match in each state
+	Matches [][]int
+	// Transitions of each state, sorted by rune. First state is start.
+	Transitions [][]AutomatonTransition
+}
+
+// AutomatonTransition is next state for characters from Low to High
+type AutomatonTransition struct {
+	Low  rune
+	High rune
+	Next int
+}
+
+// A thread is an instruction of program of a token
+type automatonThread struct {
+	token int
+	pc    uint32
+}
+
+// State of automaton: threads of each token, ordered by token and priority
+type automatonState struct {
+	threads []automatonThread
+	// Tokens that match at this position
+	matches []int
+	// Next state of ASCII characters
+	next [utf8.RuneSelf]*automatonState
+	// Next state of other characters
+	nextRune map[rune]*automatonState
+}
+
+// NewAutomaton create automaton of tokens
+func NewAutomaton(tokensList []TokenEntry) *Automaton {
+	a := &Automaton{
+		progs: make([]*syntax.Prog, len(tokensList)),
+	}
+
+	for index, token := range tokensList {
+		a.progs[index] = automatonProg(token)
+	}
+
+	a.reset()
+
+	return a
+}
+
+// Compile program of token. Return nil if token can't be in automaton.
+func automatonProg(token TokenEntry) *syntax.Prog {
+	var value string
+
+	switch token.TypeOf {
+	case HardValue:
+		if token.Flags&FlagCaseInsensitive != 0 && !isASCII(token.Value) {
+			// Case folding can change length in bytes
+			return nil
+		}
+
+		value = regexFlags(token.Flags&FlagCaseInsensitive) + regexp.QuoteMeta(token.Value)
+	case RegexValue:
+		value = regexFlags(token.Flags) + token.Value
+	default:
+		return nil
+	}
+
+	re, err := syntax.Parse(value, syntax.Perl)
+
+	if err != nil {
+		return nil
+	}
+
+	prog, err := syntax.Compile(re.Simplify())
+
+	if err != nil {
+		return nil
+	}
+
+	for _, inst := range prog.Inst {
+		if inst.Op == syntax.InstEmptyWidth {
+			return nil
+		}
+	}
+
+	return prog
+}
+
+func isASCII(str string) bool {
+	for index := 0; index < len(str); index++ {
+		if str[index] >= utf8.RuneSelf {
+			return false
+		}
+	}
+
+	return true
+}
+
+// NewAutomatonTable build all states of automaton of tokens.
+// Return an error if automaton has too many states.
+func NewAutomatonTable(tokensList []TokenEntry) (AutomatonTable, error) {
+	a := NewAutomaton(tokensList)
+	classes := a.runeClasses()
+	table := AutomatonTable{Tokens: make([]bool, len(a.progs))}
+
+	for index := range a.progs {
+		table.Tokens[index] = a.handle(index)
+	}
+
+	indexes := map[*automatonState]int{a.start: 0}
+	queue := []*automatonState{a.start}
+
+	for current := 0; current < len(queue); current++ {
+		state := queue[current]
+		transitions := []AutomatonTransition{}
+
+		for class := 0; class+1 < len(classes); class++ {
+			if len(a.states) >= automatonMaxStates {
+				return AutomatonTable{}, fmt.Errorf("too many states in automaton (max %d)", automatonMaxStates)
+			}
+
+			low, high := classes[class], classes[class+1]-1
+			next := a.step(state, low)
+
+			if len(next.threads) == 0 && len(next.matches) == 0 {
+				continue
+			}
+
+			if _, ok := indexes[next]; !ok {
+				indexes[next] = len(queue)
+				queue = append(queue, next)
+			}
+
+			last := len(transitions) - 1
+
+			if last >= 0 && transitions[last].Next == indexes[next] && transitions[last].High == low-1 {
+				transitions[last].High = high
+			} else {
+				transitions = append(transitions, AutomatonTransition{Low: low, High: high, Next: indexes[next]})
+			}
+		}
+
+		table.Matches = append(table.Matches, state.matches)
+		table.Transitions = append(table.Transitions, transitions)
+	}
+
+	return table, nil
+}
+
+// Return first character of each class of characters: all characters of a
+// class have same transitions. Last item is end of last class.
+func (a *Automaton) runeClasses() []rune {
+	bounds := map[rune]bool{0: true, utf8.RuneSelf: true, unicode.MaxRune + 1: true}
+	add := func(low rune, high rune) {
+		bounds[low] = true
+		bounds[high+1] = true
+	}
+
+	for _, prog := range a.progs {
+		if prog == nil {
+			continue
+		}
+
+		for _, inst := range prog.Inst {
+			switch inst.Op {
+			case syntax.InstRune:
+				if len(inst.Rune) == 1 {
+					char := inst.Rune[0]
+					add(char, char)
+
+					if syntax.Flags(inst.Arg)&syntax.FoldCase != 0 {
+						for fold := unicode.SimpleFold(char); fold != char; fold = unicode.SimpleFold(fold) {
+							add(fold, fold)
+						}
+					}
+				}
+
+				for index := 0; index+1 < len(inst.Rune); index += 2 {
+					add(inst.Rune[index], inst.Rune[index+1])
+				}
+			case syntax.InstRune1:
+				add(inst.Rune[0], inst.Rune[0])
+			case syntax.InstRuneAnyNotNL:
+				add('\n', '\n')
+			}
+		}
+	}
+
+	classes := make([]rune, 0, len(bounds))
+
+	for bound := range bounds {
+		classes = append(classes, bound)
+	}
+
+	sort.Slice(classes, func(i, j int) bool { return classes[i] < classes[j] })
+
+	return classes
+}
+
+// NewTableAutomaton create automaton with all states in table
+func NewTableAutomaton(table AutomatonTable) *Automaton {
+	a := &Automaton{
+		table: &table,
+		ascii: make([][utf8.RuneSelf]int32, len(table.Transitions)),
+	}
+
+	for state, transitions := range table.Transitions {
+		for char := range a.ascii[state] {
+			a.ascii[state][char] = -1
+		}
+
+		for _, transition := range transitions {
+			for char := transition.Low; char <= transition.High && char < utf8.RuneSelf; char++ {
+				a.ascii[state][char] = int32(transition.Next)
+			}
+		}
+	}
+
+	return a
+}
+
+// Return true if token at index is searched by automaton.
+func (a *Automaton) handle(index int) bool {
+	if a.table != nil {
+		return index < len(a.table.Tokens) && a.table.Tokens[index]
+	}
+
+	return index < len(a.progs) && a.progs[index] != nil
+}
+
+// Clear cache of states.
+func (a *Automaton) reset() {
+	a.states = map[string]*automatonState{}
+	threads := []automatonThread{}
+	matches := []int{}
+
+	for index, prog := range a.progs {
+		if prog != nil {
+			threads, matches = a.addThread(threads, matches, index, uint32(prog.Start), map[uint32]bool{})
+		}
+	}
+
+	a.start = a.state(threads, matches)
+}
+
+// Return length of match at start of text of each token, -1 if not found.
+// Memory of lengths is reused if large enough.
+func (a *Automaton) match(text string, lengths []int) []int {
+	if a.table != nil {
+		lengths = resetLengths(lengths, len(a.table.Tokens))
+
+		return a.matchTable(text, lengths)
+	}
+
+	lengths = resetLengths(lengths, len(a.progs))
+
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	state := a.start
+	pos := 0
+
+	for {
+		for _, token := range state.matches {
+			// Last match is match of thread with highest priority
+			lengths[token] = pos
+		}
+
+		if len(state.threads) == 0 || pos >= len(text) {
+			return lengths
+		}
+
+		char, size := rune(text[pos]), 1
+
+		if char >= utf8.RuneSelf {
+			char, size = utf8.DecodeRuneInString(text[pos:])
+		}
+
+		next := a.cached(state, char)
+
+		if next == nil {
+			// Only one goroutine build state
+			a.mutex.RUnlock()
+			a.mutex.Lock()
+			next = a.step(state, char)
+			a.mutex.Unlock()
+			a.mutex.RLock()
+		}
+
+		state = next
+		pos += size
+	}
+}
+
+// Return length of match of each token, with table.
+func (a *Automaton) matchTable(text string, lengths []int) []int {
+	state := 0
+	pos := 0
+
+	for {
+		for _, token := range a.table.Matches[state] {
+			lengths[token] = pos
+		}
+
+		transitions := a.table.Transitions[state]
+
+		if len(transitions) == 0 || pos >= len(text) {
+			return lengths
+		}
+
+		char, size := rune(text[pos]), 1
+
+		if char < utf8.RuneSelf {
+			state = int(a.ascii[state][char])
+		} else {
+			char, size = utf8.DecodeRuneInString(text[pos:])
+			index := sort.Search(len(transitions), func(i int) bool { return transitions[i].High >= char })
+			state = -1
+
+			if index < len(transitions) && transitions[index].Low <= char {
+				state = transitions[index].Next
+			}
+		}
+
+		if state < 0 {
+			return lengths
+		}
+
+		pos += size
+	}
+}
+
+// Return lengths with size elements set to -1.
+func resetLengths(lengths []int, size int) []int {
+	if cap(lengths) < size {
+		lengths = make([]int, size)
+	}
+
+	lengths = lengths[:size]
+
+	for index := range lengths {
+		lengths[index] = -1
+	}
+
+	return lengths
+}
+
+// Return next state of state with char if already built, otherwise nil.
+func (a *Automaton) cached(state *automatonState, char rune) *automatonState {
+	if char < utf8.RuneSelf {
+		return state.next[char]
+	}
+
+	return state.nextRune[char]
+}
+
+// Return next state of state with char, build it if needed.
+func (a *Automaton) step(state *automatonState, char rune) *automatonState {
+	if next := a.cached(state, char); next != nil {
+		return next
+	}
+
+	if len(a.states) >= automatonMaxStates {
+		a.reset()
+	}
+
+	threads := []automatonThread{}
+	matches := []int{}
+	// Token matched, threads with lower priority are cut
+	matched := -1
+	var visited map[uint32]bool
+
+	for index, thread := range state.threads {
+		if thread.token == matched {
+			continue
+		}
+
+		if index == 0 || state.threads[index-1].token != thread.token {
+			visited = map[uint32]bool{}
+		}
+
+		inst := &a.progs[thread.token].Inst[thread.pc]
+
+		if !matchRune(inst, char) {
+			continue
+		}
+
+		count := len(matches)
+		threads, matches = a.addThread(threads, matches, thread.token, inst.Out, visited)
+
+		if len(matches) > count {
+			matched = thread.token
+		}
+	}
+
+	next := a.state(threads, matches)
+
+	if char < utf8.RuneSelf {
+		state.next[char] = next
+	} else {
+		if state.nextRune == nil {
+			state.nextRune = map[rune]*automatonState{}
+		}
+
+		state.nextRune[char] = next
+	}
+
+	return next
+}
+
+// Check if instruction accept char.
+func matchRune(inst *syntax.Inst, char rune) bool {
+	switch inst.Op {
+	case syntax.InstRune, syntax.InstRune1:
+		return inst.MatchRune(char)
+	case syntax.InstRuneAny:
+		return true
+	case syntax.InstRuneAnyNotNL:
+		return char != '\n'
+	}
+
+	return false
+}
+
+// Add thread and threads that follow it without reading character, in order of
+// priority. Stop at match of token, because threads after have lower priority.
+func (a *Automaton) addThread(threads []automatonThread, matches []int, token int, pc uint32, visited map[uint32]bool) ([]automatonThread, []int) {
+	if visited[pc] || (len(matches) > 0 && matches[len(matches)-1] == token) {
+		return threads, matches
+	}
+
+	visited[pc] = true
+	inst := &a.progs[token].Inst[pc]
+
+	switch inst.Op {
+	case syntax.InstAlt, syntax.InstAltMatch:
+		threads, matches = a.addThread(threads, matches, token, inst.Out, visited)
+		threads, matches = a.addThread(threads, matches, token, inst.Arg, visited)
+	case syntax.InstCapture, syntax.InstNop:
+		threads, matches = a.addThread(threads, matches, token, inst.Out, visited)
+	case syntax.InstMatch:
+		matches = append(matches, token)
+	case syntax.InstRune, syntax.InstRune1, syntax.InstRuneAny, syntax.InstRuneAnyNotNL:
+		threads = append(threads, automatonThread{token: token, pc: pc})
+	}
+
+	return threads, matches
+}
+
+// Return state of threads from cache.
+func (a *Automaton) state(threads []automatonThread, matches []int) *automatonState {
+	key := make([]byte, 0, 8*len(threads)+4*len(matches)+4)
+
+	for _, thread := range threads {
+		key = appendUint32(key, uint32(thread.token))
+		key = appendUint32(key, thread.pc)
+	}
+
+	key = appendUint32(key, ^uint32(0))
+
+	for _, token := range matches {
+		key = appendUint32(key, uint32(token))
+	}
+
+	if state, ok := a.states[string(key)]; ok {
+		return state
+	}
+
+	state := &automatonState{threads: threads, matches: matches}
+	a.states[string(key)] = state
+
+	return state
+}
+
+func appendUint32(data []byte, value uint32) []byte {
+	return append(data, byte(value), byte(value>>8), byte(value>>16), byte(value>>24))
+}
+
+// Return true if messages of level are logged. Use it before log call with
+// arguments, to not convert arguments if log is disable.
+func (o *LexerOptions) isLogEnabled(level int) bool {
+	return o.Logger != nil && o.LogLevel >= level
+}
+
+func (o *LexerOptions) errorLog(methodName, format string, a ...interface{}) {
+	if o.isLogEnabled(LexerLogError) {
+		o.Logger.Error(fmt.Sprintf(format, a...), "method", methodName)
+	}
+}
+
+func (o *LexerOptions) infoLog(methodName, format string, a ...interface{}) {
+	if o.isLogEnabled(LexerLogInfo) {
+		o.Logger.Info(fmt.Sprintf(format, a...), "method", methodName)
+	}
+}
+
+func (o *LexerOptions) debugLog(methodName, format string, a ...interface{}) {
+	if o.isLogEnabled(LexerLogDebug) {
+		o.Logger.Debug(fmt.Sprintf(format, a...), "method", methodName)
+	}
+}
+
+// Logger write messages of lexer. args are pairs of key and value, like
+// log/slog (a *slog.Logger is a Logger).
+type Logger interface {
+	Debug(msg string, args ...interface{})
+	Info(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+}
+
+// NewLogger create logger that write one line by message in w (e.g.
+// os.Stderr): "[DEBUG] message key=value". Logger is safe for concurrent use.
+func NewLogger(w io.Writer) Logger {
+	return &writerLogger{w: w}
+}
+
+type writerLogger struct {
+	mutex sync.Mutex
+	w     io.Writer
+}
+
+func (l *writerLogger) Debug(msg string, args ...interface{}) {
+	l.write("DEBUG", msg, args)
+}
+
+func (l *writerLogger) Info(msg string, args ...interface{}) {
+	l.write("INFO", msg, args)
+}
+
+func (l *writerLogger) Warn(msg string, args ...interface{}) {
+	l.write("WARN", msg, args)
+}
+
+func (l *writerLogger) Error(msg string, args ...interface{}) {
+	l.write("ERROR", msg, args)
+}
+
+func (l *writerLogger) write(level string, msg string, args []interface{}) {
+	line := fmt.Sprintf("[%s] %s", level, msg)
+
+	for index := 0; index+1 < len(args); index += 2 {
+		line += fmt.Sprintf(" %v=%v", args[index], args[index+1])
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	fmt.Fprintln(l.w, line)
+}
+
+// Return true if string contains only space or tab.
+func isBlank(str string) bool {
+	return strings.Trim(str, " \t") == ""
+}
+
+// Source is text with position of all end of lines, to find line and column of
+// an offset without read text again
+type Source struct {
+	text string
+	// Text read, grows without copy text at each Append. Only used after first
+	// Append, text given to NewSource is not copied.
+	buffer strings.Builder
+	// End of lines (NewlineLF...)
+	policy int
+	// Start of end of lines
+	lineEnds []int
+	// Start of lines, first line start at 0
+	lineStarts []int
+	// Text is read until this offset
+	scanned int
+}
+
+// NewSource create source of text. newlines is end of lines (NewlineLF...).
+func NewSource(text string, newlines int) *Source {
+	if newlines == 0 {
+		newlines = NewlineDefault
+	}
+
+	s := &Source{text: text, policy: newlines, lineStarts: []int{0}}
+	s.scanLines(0)
+
+	return s
+}
+
+// Append add text at end of source, e.g. to read a stream
+func (s *Source) Append(text string) {
+	previousLength := len(s.text)
+
+	if s.buffer.Len() == 0 {
+		s.buffer.WriteString(s.text)
+	}
+
+	s.buffer.WriteString(text)
+	s.text = s.buffer.String()
+	s.scanLines(previousLength)
+}
+
+// Find end of lines in text added after previousLength
+func (s *Source) scanLines(previousLength int) {
+	if last := len(s.lineEnds) - 1; last >= 0 && s.lineStarts[last+1] == previousLength {
+		// "\r" at end of previous text can be start of "\r\n"
+		if end := s.lineEnds[last] + lineEndAt(s.text, s.lineEnds[last], s.policy); end > previousLength {
+			s.lineStarts[last+1] = end
+			s.scanned = end
+		}
+	}
+
+	for s.scanned < len(s.text) {
+		if !utf8.FullRuneInString(s.text[s.scanned:]) && s.text[s.scanned] >= utf8.RuneSelf {
+			// Wait next text to read end of line U+2028...
+			return
+		}
+
+		if s.text[s.scanned] == '\r' && s.scanned+1 == len(s.text) && s.policy&NewlineCRLF != 0 && s.policy&NewlineCR == 0 {
+			// Wait next text to know if "\r" is start of "\r\n"
+			return
+		}
+
+		length := lineEndAt(s.text, s.scanned, s.policy)
+
+		if length == 0 {
+			s.scanned++
+			continue
+		}
+
+		s.lineEnds = append(s.lineEnds, s.scanned)
+		s.scanned += length
+		s.lineStarts = append(s.lineStarts, s.scanned)
+	}
+}
+
+// Text return text of source
+func (s *Source) Text() string {
+	return s.text
+}
+
+// LineCount return number of lines
+func (s *Source) LineCount() int {
+	return len(s.lineStarts)
+}
+
+// Line return text of line (start at 1), without end of line
+func (s *Source) Line(line int) string {
+	if line < 1 || line > len(s.lineStarts) {
+		return ""
+	}
+
+	start, end := s.lineBounds(line - 1)
+
+	return s.text[start:end]
+}
+
+// Return start and end (without end of line) of line at index.
+func (s *Source) lineBounds(index int) (int, int) {
+	if index < len(s.lineEnds) {
+		return s.lineStarts[index], s.lineEnds[index]
+	}
+
+	return s.lineStarts[index], len(s.text)
+}
+
+// Position return line and column (in bytes) of offset, start at 1. Offset
+// out of text is position of start or end of text.
+func (s *Source) Position(offset int) (int, int) {
+	offset = clamp(offset, 0, len(s.text))
+	line := sort.SearchInts(s.lineStarts, offset+1)
+
+	return line, offset - s.lineStarts[line-1] + 1
+}
+
+// Count end of lines that start between start and end, and return start of
+// last line in text from start to end. cursor is index of next end of line
+// after previous call, texts must be read in order.
+func (s *Source) countLineEnds(cursor *int, start int, end int) (int, int) {
+	count := 0
+	lastLineStart := start
+
+	if *cursor > 0 && s.lineStarts[*cursor] > start {
+		// "\r\n" start in previous text, already counted
+		lastLineStart = min2(s.lineStarts[*cursor], end)
+	}
+
+	for *cursor < len(s.lineEnds) && s.lineEnds[*cursor] < end {
+		count++
+		*cursor++
+		// "\r\n" can finish in next text
+		lastLineStart = min2(s.lineStarts[*cursor], end)
+	}
+
+	return count, lastLineStart
+}
+
+// Return value in range [low, high].
+func clamp(value int, low int, high int) int {
+	if value < low {
+		return low
+	}
+
+	return min2(value, high)
+}
+
+func min2(a int, b int) int {
+	if b < a {
+		return b
+	}
+
+	return a
+}
+
+// Return length in bytes of end of line at index of text, 0 if no end of line.
+func lineEndAt(text string, index int, policy int) int {
+	if policy == 0 {
+		policy = NewlineDefault
+	}
+
+	switch text[index] {
+	case '\n':
+		if policy&NewlineLF != 0 {
+			return 1
+		}
+	case '\r':
+		if policy&NewlineCRLF != 0 && index+1 < len(text) && text[index+1] == '\n' {
+			return 2
+		}
+
+		if policy&NewlineCR != 0 {
+			return 1
+		}
+	case '\f':
+		if policy&NewlineFormFeed != 0 {
+			return 1
+		}
+	case 0xC2, 0xE2:
+		if policy&NewlineUnicode != 0 {
+			char, size := utf8.DecodeRuneInString(text[index:])
+
+			if char == 0x85 || char == 0x2028 || char == 0x2029 {
+				return size
+			}
+		}
+	}
+
+	return 0
+}
+
+// Replace all end of lines of text by '\n'.
+func normalizeNewlines(text string, policy int) string {
+	var result strings.Builder
+
+	for index := 0; index < len(text); {
+		length := lineEndAt(text, index, policy)
+
+		if length == 0 {
+			result.WriteByte(text[index])
+			index++
+		} else {
+			result.WriteByte('\n')
+			index += length
+		}
 	}
 
-	// FindAllStringIndex return an array of array [begin end], we take last
-	// and return also number of item found that represent number of line found.
-	return l, pos[l-1]
+	return result.String()
 }
@@ -1,17 +1,17 @@
 package lexer
 
 import (
-	"os"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"reflect"
+	"runtime"
+	"strings"
 	"testing"
+	"testing/fstest"
 )
 
-func TestMain(m *testing.M) {
-	LexerLogLevel = LexerLogNone
-	code := m.Run()
-
-	os.Exit(code)
-}
-
 func Test_Lexer_One_Token_HardValue(t *testing.T) {
 	tokensList := []TokenEntry{
 		NewHardValueToken("MODULE", "module", 1),
@@ -223,7 +223,39 @@ func Test_Lexer_Token_Not_Found(t *testing.T) {
 		t.Errorf("A token was found! %+v", tokens)
 	}
 
-	if err.Error() != "invalid token found at 1:1\nttt\n^" {
+	if err.Error() != "1:1: invalid token found\n1 | tttt\n  | ^" {
+		t.Errorf("Wrong error message:'%s'", err.Error())
+	}
+}
+
+func Test_Lexer_Token_Not_Found_Error_Value(t *testing.T) {
+	tokensList := []TokenEntry{
+		NewRegexValueToken("_NEWLINE", "(\\r|\\n|\\r\\n)", -1),
+		NewHardValueToken("MODULE", "module", 1).WithFlags(FlagLineStart),
+		NewHardValueToken("END", "end", 2),
+	}
+
+	options := NewLexerOptions()
+	options.FileName = "test.src"
+
+	_, err := LexerWithOptions("module\nend module\n", tokensList, options)
+
+	var lexError *LexError
+
+	if !errors.As(err, &lexError) {
+		t.Errorf("Error is not a LexError: %+v", err)
+		return
+	}
+
+	if lexError.File != "test.src" || lexError.Line != 2 || lexError.Column != 4 || lexError.Offset != 10 {
+		t.Errorf("Expected {File:test.src Line:2 Column:4 Offset:10} found %+v", lexError)
+	}
+
+	if len(lexError.RulesTried) != 2 || lexError.RulesTried[0] != "_NEWLINE" || lexError.RulesTried[1] != "END" {
+		t.Errorf("Expected rules tried [_NEWLINE END] found %+v", lexError.RulesTried)
+	}
+
+	if err.Error() != "test.src:2:4: invalid token found\n2 | end module\n  |    ^" {
 		t.Errorf("Wrong error message:'%s'", err.Error())
 	}
 }
@@ -301,6 +333,1425 @@ func Test_Lexer_Token_With_SubPattern_Callback(t *testing.T) {
 	}
 }
 
+func Test_Lexer_Token_Line_Start(t *testing.T) {
+	tokensList := []TokenEntry{
+		NewRegexValueToken("_NEWLINE", "(\\r|\\n|\\r\\n)", -1),
+		NewRegexValueToken("_SPACE", "([ \\t]+)", -1),
+		NewRegexValueToken("SECTION", "(\\[[a-z]+\\])", 1).WithFlags(FlagLineStart),
+		NewRegexValueToken("VALUE", "([^\\s]+)", 2),
+	}
+
+	tokens, err := Lexer("[main] [value]\n[other]", tokensList)
+
+	if err != nil {
+		t.Errorf("An error occure %+v", err)
+	}
+
+	if len(tokens) != 3 {
+		t.Errorf("Only three tokens normaly return. It return %d tokens", len(tokens))
+		return
+	}
+
+	if tokens[0].Name != "SECTION" || tokens[1].Name != "VALUE" || tokens[2].Name != "SECTION" {
+		t.Errorf("Expected SECTION VALUE SECTION found %+v ", tokens)
+	}
+}
+
+func Test_Lexer_Token_First_Non_Blank(t *testing.T) {
+	tokensList := []TokenEntry{
+		NewRegexValueToken("_NEWLINE", "(\\r|\\n|\\r\\n)", -1),
+		NewRegexValueToken("_SPACE", "([ \\t]+)", -1),
+		NewHardValueToken("DIRECTIVE", "#", 1).WithFlags(FlagFirstNonBlank),
+		NewRegexValueToken("VALUE", "([^\\s]+)", 2),
+	}
+
+	tokens, err := Lexer("  \t# a#\n\n #", tokensList)
+
+	if err != nil {
+		t.Errorf("An error occure %+v", err)
+	}
+
+	if len(tokens) != 3 {
+		t.Errorf("Only three tokens normaly return. It return %d tokens", len(tokens))
+		return
+	}
+
+	if tokens[0].Name != "DIRECTIVE" || tokens[1].Name != "VALUE" || tokens[1].Data != "a#" || tokens[2].Name != "DIRECTIVE" {
+		t.Errorf("Expected DIRECTIVE VALUE DIRECTIVE found %+v ", tokens)
+	}
+
+	tk := tokens[2]
+
+	if tk.LineNumber != 3 || tk.StartPos != 2 {
+		t.Errorf("Expected {LineNumber:3 StartPos:2} found %+v ", tk)
+	}
+}
+
+func Test_Lexer_Token_Case_Insensitive(t *testing.T) {
+	tokensList := []TokenEntry{
+		NewRegexValueToken("_SPACE", "(\\s)", -1),
+		NewHardValueToken("SELECT", "select", 1).WithFlags(FlagCaseInsensitive),
+		NewRegexWithSubValueToken(
+			"IDENTIFIER",
+			"([a-z]+)",
+			[]SubPattern{
+				{"FROM", 2, "from"},
+			},
+			3,
+		).WithFlags(FlagCaseInsensitive),
+	}
+
+	tokens, err := Lexer("SeLeCt Name FROM", tokensList)
+
+	if err != nil {
+		t.Errorf("An error occure %+v", err)
+	}
+
+	if len(tokens) != 3 {
+		t.Errorf("Only three tokens normaly return. It return %d tokens", len(tokens))
+		return
+	}
+
+	if tokens[0].Name != "SELECT" || tokens[0].Data != "SeLeCt" {
+		t.Errorf("Expected {Name:SELECT Data:SeLeCt} found %+v ", tokens[0])
+	}
+
+	if tokens[1].Name != "IDENTIFIER" || tokens[1].Data != "Name" {
+		t.Errorf("Expected {Name:IDENTIFIER Data:Name} found %+v ", tokens[1])
+	}
+
+	if tokens[2].Name != "FROM" || tokens[2].Data != "FROM" {
+		t.Errorf("Expected {Name:FROM Data:FROM} found %+v ", tokens[2])
+	}
+}
+
+func Test_Lexer_Token_Dot_New_Line(t *testing.T) {
+	tokensList := []TokenEntry{
+		NewRegexValueToken("COMMENT", "(/\\*.*?\\*/)", 1).WithFlags(FlagDotNewLine),
+	}
+
+	tokens, err := Lexer("/* a\nb */", tokensList)
+
+	if err != nil {
+		t.Errorf("An error occure %+v", err)
+	}
+
+	if len(tokens) != 1 || tokens[0].Lenght != 9 {
+		t.Errorf("Expected one token with length 9 found %+v ", tokens)
+	}
+}
+
+func Test_Lexer_Token_Regex_Match_Empty(t *testing.T) {
+	tokensList := []TokenEntry{
+		NewRegexValueToken("_SPACE", "(\\s*)", -1),
+		NewRegexValueToken("IDENTIFIER", "([a-z]+)", 1),
+	}
+
+	tokens, err := Lexer("ab  cd", tokensList)
+
+	if err != nil {
+		t.Errorf("An error occure %+v", err)
+	}
+
+	if len(tokens) != 2 || tokens[0].Data != "ab" || tokens[1].Data != "cd" {
+		t.Errorf("Expected two IDENTIFIER tokens found %+v ", tokens)
+	}
+}
+
+func Test_Lexer_Token_Callback_Return_Empty(t *testing.T) {
+	tokensList := []TokenEntry{
+		NewHardValueToken("A", "a", 1),
+		NewFunctionCallToken("EMPTY", func(text string, token TokenEntry) (Token, bool) {
+			return Token{Name: token.Name, IDValue: token.IDValue}, true
+		}, 2),
+	}
+
+	_, err := Lexer("ab", tokensList)
+
+	if err == nil {
+		t.Errorf("No error when callback return empty token")
+	} else if err.Error() != "1:2: zero-length token returned by rule 'EMPTY'\n1 | ab\n  |  ^" {
+		t.Errorf("Wrong error message:'%s'", err.Error())
+	}
+}
+
+func Test_Lexer_Error_Recovery(t *testing.T) {
+	tokensList := []TokenEntry{
+		NewRegexValueToken("_SPACE", "(\\s)", -1),
+		NewRegexValueToken("NUMBER", "([0-9]+)", 1),
+	}
+
+	options := NewLexerOptions()
+	options.ErrorRecovery = true
+	options.ErrorIDValue = 99
+
+	tokens, err := LexerWithOptions("1 a 2\n#3", tokensList, options)
+
+	errs, ok := err.(LexerErrors)
+
+	if !ok || len(errs) != 2 {
+		t.Errorf("Expected two errors found %+v", err)
+		return
+	}
+
+	if errs[0].Error() != "1:3: invalid token found\n1 | 1 a 2\n  |   ^" || errs[1].Error() != "2:1: invalid token found\n2 | #3\n  | ^" {
+		t.Errorf("Wrong error message:'%s'", err.Error())
+	}
+
+	if len(tokens) != 5 {
+		t.Errorf("Only five tokens normaly return. It return %d tokens", len(tokens))
+		return
+	}
+
+	tk := tokens[1]
+
+	if tk.Name != ErrorTokenName || tk.IDValue != 99 || tk.LineNumber != 1 || tk.StartPos != 3 || tk.Lenght != 1 || tk.Data != "a" {
+		t.Errorf("Expected {Name:ERROR IDValue:99 LineNumber:1 StartPos:3 Lenght:1 Data:a} found %+v ", tk)
+	}
+
+	tk = tokens[4]
+
+	if tk.Name != "NUMBER" || tk.LineNumber != 2 || tk.StartPos != 2 || tk.Data != "3" {
+		t.Errorf("Expected {Name:NUMBER LineNumber:2 StartPos:2 Data:3} found %+v ", tk)
+	}
+}
+
+func Test_Lexer_Error_Recovery_Default_ID(t *testing.T) {
+	tokensList := []TokenEntry{
+		NewRegexValueToken("_SPACE", "(\\s)", -1),
+		NewRegexValueToken("NUMBER", "([0-9]+)", 1),
+	}
+
+	options := NewLexerOptions()
+	options.ErrorRecovery = true
+
+	tokens, _ := LexerWithOptions("1 a", tokensList, options)
+
+	if len(tokens) != 2 || tokens[1].Name != ErrorTokenName || tokens[1].IDValue != ErrorToken {
+		t.Errorf("Expected error token with IDValue ErrorToken found %+v", tokens)
+	}
+
+	options.ErrorIDValue = SkipToken
+
+	tokens, _ = LexerWithOptions("1 a", tokensList, options)
+
+	if len(tokens) != 1 || tokens[0].Name != "NUMBER" {
+		t.Errorf("Expected no error token found %+v", tokens)
+	}
+}
+
+func Test_Lexer_Error_Recovery_End_Of_Line_CR(t *testing.T) {
+	tokensList := []TokenEntry{
+		NewRegexValueToken("_SPACE", "( |\\r)", -1),
+		NewRegexValueToken("NUMBER", "([0-9]+)", 1),
+	}
+
+	options := NewLexerOptions()
+	options.ErrorRecovery = true
+	options.Newlines = NewlineCR
+	options.Recovery = RecoverySkipToEndOfLineWith(NewlineCR)
+
+	tokens, _ := LexerWithOptions("1 a b\r2", tokensList, options)
+
+	if len(tokens) != 3 || tokens[1].Data != "a b" || tokens[2].Data != "2" || tokens[2].LineNumber != 2 {
+		t.Errorf("Expected tokens 1, 'a b' and 2 at line 2 found %+v", tokens)
+	}
+
+	if RecoverySkipToEndOfLine("a b\r2") != 5 {
+		t.Errorf("Expected \"\\r\" is not end of line by default")
+	}
+}
+
+func Test_Lexer_Error_Recovery_Too_Long(t *testing.T) {
+	tokensList := []TokenEntry{
+		NewRegexValueToken("NUMBER", "([0-9]+)", 1),
+	}
+
+	options := NewLexerOptions()
+	options.ErrorRecovery = true
+	options.Recovery = func(text string) int {
+		return len(text) + 10
+	}
+
+	tokens, err := LexerWithOptions("1ab", tokensList, options)
+
+	if errs, ok := err.(LexerErrors); !ok || len(errs) != 1 {
+		t.Errorf("Expected one error found %+v", err)
+	}
+
+	if len(tokens) != 2 || tokens[1].Data != "ab" || tokens[1].Lenght != 2 {
+		t.Errorf("Expected error token 'ab' found %+v", tokens)
+	}
+}
+
+func Test_Lexer_Error_Recovery_Strategy_And_Max_Errors(t *testing.T) {
+	tokensList := []TokenEntry{
+		NewRegexValueToken("_SPACE", "(\\s)", -1),
+		NewRegexValueToken("NUMBER", "([0-9]+)", 1),
+	}
+
+	options := NewLexerOptions()
+	options.ErrorRecovery = true
+	options.ErrorIDValue = 99
+	options.Recovery = RecoverySkipToSpace
+	options.MaxErrors = 2
+
+	tokens, err := LexerWithOptions("abc 1 def 2 ghi 3", tokensList, options)
+
+	errs, ok := err.(LexerErrors)
+
+	if !ok || len(errs) != 2 {
+		t.Errorf("Expected two errors found %+v", err)
+		return
+	}
+
+	if len(tokens) != 3 || tokens[0].Data != "abc" || tokens[1].Data != "1" || tokens[2].Data != "def" {
+		t.Errorf("Expected tokens abc, 1 and def found %+v", tokens)
+	}
+}
+
+func Test_Render_Diagnostic_With_Context(t *testing.T) {
+	text := "line 1\nline 2\n\tvalue = 12\nline 4\nline 5"
+	options := NewDiagnosticOptions()
+	options.ContextBefore = 1
+	options.ContextAfter = 1
+
+	diagnostic := Diagnostic{
+		Message: "invalid value",
+		File:    "test.src",
+		Line:    9,
+		Column:  10,
+		Offset:  23,
+		Length:  2,
+	}
+
+	result := RenderDiagnostic(text, diagnostic, options)
+	expected := "test.src:9:10: invalid value\n" +
+		" 8 | line 2\n" +
+		" 9 |     value = 12\n" +
+		"   |             ^^\n" +
+		"10 | line 4"
+
+	if result != expected {
+		t.Errorf("Wrong diagnostic:\n%s", result)
+	}
+}
+
+func Test_Render_Diagnostic_Wide_Rune(t *testing.T) {
+	text := "\u65e5\u672c = x"
+	diagnostic := NewTokenDiagnostic(Token{LineNumber: 1, StartPos: 10, Offset: 9, Lenght: 1}, "unknown")
+
+	result := RenderDiagnostic(text, diagnostic, NewDiagnosticOptions())
+	expected := "1:10: unknown\n1 | \u65e5\u672c = x\n  |        ^"
+
+	if result != expected {
+		t.Errorf("Wrong diagnostic:\n%s", result)
+	}
+}
+
+func Test_Render_Diagnostic_Offset_Before_Text(t *testing.T) {
+	diagnostic := Diagnostic{Message: "unknown", Line: 1, Column: 1, Offset: 0, Length: 1}
+	options := NewDiagnosticOptions()
+	options.StartOffset = 10
+
+	result := RenderDiagnostic("abc", diagnostic, options)
+	expected := "1:1: unknown\n1 | abc\n  | ^"
+
+	if result != expected {
+		t.Errorf("Wrong diagnostic:\n%s", result)
+	}
+}
+
+func Test_Lexer_Suggest_Keyword(t *testing.T) {
+	tokensList := []TokenEntry{
+		NewRegexValueToken("_SPACE", "(\\s)", -1),
+		NewRegexWithSubValueToken(
+			"IDENTIFIER",
+			"([a-zA-Z]+)",
+			[]SubPattern{
+				{"PRINT", 2, "print"},
+				{"IF", 3, "if"},
+			},
+			1,
+		),
+	}
+
+	options := NewLexerOptions()
+	options.SuggestDistance = 2
+
+	tokens, err := LexerWithOptions("prnt print of value", tokensList, options)
+
+	if err != nil {
+		t.Errorf("An error occure %+v", err)
+	}
+
+	if len(tokens) != 4 {
+		t.Errorf("Only four tokens normaly return. It return %d tokens", len(tokens))
+		return
+	}
+
+	if tokens[0].Name != "IDENTIFIER" || tokens[0].Suggestion != "print" {
+		t.Errorf("Expected {Name:IDENTIFIER Suggestion:print} found %+v ", tokens[0])
+	}
+
+	for _, tk := range tokens[1:] {
+		if tk.Suggestion != "" {
+			t.Errorf("Expected no suggestion found %+v ", tk)
+		}
+	}
+}
+
+func Test_Lexer_Token_Offset_And_End(t *testing.T) {
+	tokensList := []TokenEntry{
+		NewFunctionCallToken("COMMENT", skipComment, 1),
+		NewRegexValueToken("_NEWLINE", "(\\r|\\n|\\r\\n)", -1),
+		NewRegexValueToken("_SPACE", "\\s", -1),
+		NewHardValueToken("MODULE", "module", 2),
+	}
+
+	tokens, err := Lexer("module /* a\r\nbc */\n  module", tokensList)
+
+	if err != nil {
+		t.Errorf("An error occure %+v", err)
+	}
+
+	if len(tokens) != 3 {
+		t.Errorf("Only three tokens normaly return. It return %d tokens", len(tokens))
+		return
+	}
+
+	tk := tokens[0]
+
+	if tk.Offset != 0 || tk.EndLine != 1 || tk.EndColumn != 7 {
+		t.Errorf("Expected {Offset:0 EndLine:1 EndColumn:7} found %+v ", tk)
+	}
+
+	tk = tokens[1]
+
+	if tk.Offset != 7 || tk.LineNumber != 1 || tk.StartPos != 8 || tk.EndLine != 2 || tk.EndColumn != 6 {
+		t.Errorf("Expected {Offset:7 LineNumber:1 StartPos:8 EndLine:2 EndColumn:6} found %+v ", tk)
+	}
+
+	tk = tokens[2]
+
+	if tk.Offset != 21 || tk.LineNumber != 3 || tk.StartPos != 3 || tk.EndLine != 3 || tk.EndColumn != 9 {
+		t.Errorf("Expected {Offset:21 LineNumber:3 StartPos:3 EndLine:3 EndColumn:9} found %+v ", tk)
+	}
+}
+
+func Test_Lexer_Column_Unit(t *testing.T) {
+	tokensList := []TokenEntry{
+		NewRegexValueToken("_SPACE", "([ \\t]+)", -1),
+		NewRegexValueToken("WORD", "([^\\s]+)", 1),
+	}
+
+	// e acute is 2 bytes, emoji is 4 bytes and 2 UTF-16 code units,
+	// japanese character is 3 bytes and 2 columns on screen
+	text := "\u00e9\U0001F600\t\u65e5 x"
+	expected := []struct {
+		unit     int
+		startPos []int
+		end      int
+	}{
+		{ColumnBytes, []int{1, 8, 12}, 13},
+		{ColumnRunes, []int{1, 4, 6}, 7},
+		{ColumnUTF16, []int{1, 5, 7}, 8},
+		{ColumnDisplay, []int{1, 5, 8}, 9},
+	}
+
+	for _, e := range expected {
+		options := NewLexerOptions()
+		options.ColumnUnit = e.unit
+
+		tokens, err := LexerWithOptions(text, tokensList, options)
+
+		if err != nil {
+			t.Errorf("An error occure %+v", err)
+		}
+
+		if len(tokens) != 3 {
+			t.Errorf("Only three tokens normaly return. It return %d tokens", len(tokens))
+			continue
+		}
+
+		for index, startPos := range e.startPos {
+			if tokens[index].StartPos != startPos {
+				t.Errorf("Unit %d: expected StartPos %d found %+v ", e.unit, startPos, tokens[index])
+			}
+		}
+
+		if tokens[2].EndColumn != e.end {
+			t.Errorf("Unit %d: expected EndColumn %d found %+v ", e.unit, e.end, tokens[2])
+		}
+	}
+}
+
+func Test_Lexer_Column_Unit_Error(t *testing.T) {
+	tokensList := []TokenEntry{
+		NewRegexValueToken("WORD", "([a-z\\x{00e9}]+)", 1),
+	}
+
+	options := NewLexerOptions()
+	options.ColumnUnit = ColumnRunes
+
+	_, err := LexerWithOptions("\u00e9t\u00e9!", tokensList, options)
+
+	if err == nil || err.Error() != "1:4: invalid token found\n1 | \u00e9t\u00e9!\n  |    ^" {
+		t.Errorf("Wrong error: %+v", err)
+	}
+}
+
+func Test_Lexer_Newline_Policy(t *testing.T) {
+	tokensList := []TokenEntry{
+		NewRegexValueToken("_NEWLINE", "(\\r|\\n|\\r\\n)", -1),
+		NewRegexValueToken("_SPACE", "([\\s\\x{2028}])", -1),
+		NewRegexValueToken("WORD", "([a-z]+)", 1),
+	}
+
+	options := NewLexerOptions()
+	options.Newlines = NewlineLF | NewlineCRLF | NewlineCR | NewlineUnicode | NewlineFormFeed
+
+	// "\r\n" is split in two tokens by _NEWLINE and count once
+	tokens, err := LexerWithOptions("a\rb\r\nc\u2028d\fe", tokensList, options)
+
+	if err != nil {
+		t.Errorf("An error occure %+v", err)
+	}
+
+	if len(tokens) != 5 {
+		t.Errorf("Only five tokens normaly return. It return %d tokens", len(tokens))
+		return
+	}
+
+	for index, tk := range tokens {
+		if tk.LineNumber != index+1 || tk.StartPos != 1 {
+			t.Errorf("Expected {LineNumber:%d StartPos:1} found %+v ", index+1, tk)
+		}
+	}
+
+	// Default policy, only "\n" and "\r\n"
+	tokens, _ = Lexer("a\rb\r\nc\u2028d", tokensList)
+
+	if len(tokens) != 4 || tokens[1].LineNumber != 1 || tokens[1].StartPos != 3 || tokens[2].LineNumber != 2 || tokens[2].StartPos != 1 || tokens[3].LineNumber != 2 {
+		t.Errorf("Expected four tokens on two lines found %+v ", tokens)
+	}
+}
+
+func Test_Lexer_Normalize_Newlines(t *testing.T) {
+	tokensList := []TokenEntry{
+		NewRegexValueToken("STRING", "(\"[^\"]*\")", 1),
+	}
+
+	options := NewLexerOptions()
+	options.Newlines = NewlineDefault | NewlineCR
+	options.NormalizeNewlines = true
+
+	tokens, err := LexerWithOptions("\"a\r\nb\rc\"", tokensList, options)
+
+	if err != nil {
+		t.Errorf("An error occure %+v", err)
+	}
+
+	if len(tokens) != 1 {
+		t.Errorf("Only one token normaly return. It return %d tokens", len(tokens))
+		return
+	}
+
+	tk := tokens[0]
+
+	if tk.Data != "\"a\nb\nc\"" || tk.Lenght != 8 || tk.EndLine != 3 || tk.EndColumn != 3 {
+		t.Errorf("Expected {Lenght:8 EndLine:3 EndColumn:3 Data:\"a\\nb\\nc\"} found %+v ", tk)
+	}
+}
+
+func Test_Lexer_Strip_BOM(t *testing.T) {
+	tokensList := []TokenEntry{
+		NewHardValueToken("MODULE", "module", 1),
+	}
+
+	tokens, err := Lexer("\xEF\xBB\xBFmodule", tokensList)
+
+	if err != nil {
+		t.Errorf("An error occure %+v", err)
+	}
+
+	// Offset is position in original text, with byte order mark
+	if len(tokens) != 1 || tokens[0].StartPos != 1 || tokens[0].Offset != 3 {
+		t.Errorf("Expected {StartPos:1 Offset:3} found %+v", tokens)
+	}
+
+	options := NewLexerOptions()
+	options.ErrorRecovery = true
+
+	_, err = LexerWithOptions("\xEF\xBB\xBFmodule x", tokensList, options)
+
+	errs, ok := err.(LexerErrors)
+
+	if !ok || len(errs) != 2 {
+		t.Errorf("Expected two errors found %+v", err)
+		return
+	}
+
+	if lexError := errs[0].(*LexError); lexError.Offset != 9 || lexError.Error() != "1:7: invalid token found\n1 | module x\n  |       ^" {
+		t.Errorf("Expected error at offset 9 found %+v", lexError)
+	}
+}
+
+func Test_Lexer_Decode_UTF16(t *testing.T) {
+	tokensList := []TokenEntry{
+		NewRegexValueToken("_SPACE", "(\\s)", -1),
+		NewRegexValueToken("WORD", "([^\\s]+)", 1),
+	}
+
+	options := NewLexerOptions()
+	options.Encoding = EncodingAuto
+
+	inputs := []string{
+		"\xFF\xFEa\x00 \x00\xe9\x00",
+		"\xFE\xFF\x00a\x00 \x00\xe9",
+	}
+
+	for _, input := range inputs {
+		tokens, err := LexerWithOptions(input, tokensList, options)
+
+		if err != nil {
+			t.Errorf("An error occure %+v", err)
+		}
+
+		if len(tokens) != 2 || tokens[0].Data != "a" || tokens[1].Data != "\u00e9" || tokens[1].StartPos != 3 {
+			t.Errorf("Expected tokens a and \u00e9 found %+v", tokens)
+		}
+	}
+}
+
+func Test_Lexer_Invalid_UTF8(t *testing.T) {
+	tokensList := []TokenEntry{
+		NewRegexValueToken("_SPACE", "(\\s)", -1),
+		NewRegexValueToken("WORD", "([^\\s]+)", 1),
+	}
+
+	options := NewLexerOptions()
+	options.ValidateUTF8 = true
+	options.ErrorRecovery = true
+	options.ErrorIDValue = 99
+
+	tokens, err := LexerWithOptions("ab\xffcd e", tokensList, options)
+
+	errs, ok := err.(LexerErrors)
+
+	if !ok || len(errs) != 1 {
+		t.Errorf("Expected one error found %+v", err)
+		return
+	}
+
+	lexError := errs[0].(*LexError)
+
+	if lexError.Message != "invalid UTF-8 sequence" || lexError.Line != 1 || lexError.Column != 3 || lexError.Offset != 2 {
+		t.Errorf("Expected {Message:invalid UTF-8 sequence Line:1 Column:3 Offset:2} found %+v", lexError)
+	}
+
+	if len(tokens) != 4 || tokens[0].Data != "ab" || tokens[1].Name != ErrorTokenName || tokens[2].Data != "cd" || tokens[3].Data != "e" {
+		t.Errorf("Expected tokens ab, ERROR, cd and e found %+v", tokens)
+	}
+}
+
+func Test_Lexer_Start_Position(t *testing.T) {
+	tokensList := []TokenEntry{
+		NewRegexValueToken("_SPACE", "(\\s)", -1),
+		NewRegexValueToken("WORD", "([a-z]+)", 1),
+	}
+
+	options := NewLexerOptions()
+	options.FileName = "query.go"
+	options.StartLine = 10
+	options.StartColumn = 15
+	options.StartOffset = 200
+
+	tokens, err := LexerWithOptions("select a\nfrom b", tokensList, options)
+
+	if err != nil {
+		t.Errorf("Unexpected error %s", err)
+		return
+	}
+
+	if len(tokens) != 4 {
+		t.Errorf("Expected 4 tokens found %+v", tokens)
+		return
+	}
+
+	if tokens[0].File != "query.go" || tokens[0].LineNumber != 10 || tokens[0].StartPos != 15 || tokens[0].Offset != 200 || tokens[0].EndColumn != 21 {
+		t.Errorf("Expected select at query.go:10:15 (offset 200) found %+v", tokens[0])
+	}
+
+	if tokens[2].LineNumber != 11 || tokens[2].StartPos != 1 || tokens[2].Offset != 209 {
+		t.Errorf("Expected from at 11:1 (offset 209) found %+v", tokens[2])
+	}
+
+	// Diagnostic of token in coordinates of file, rendered with text
+	diagnosticOptions := NewDiagnosticOptions()
+	diagnosticOptions.StartOffset = options.StartOffset
+	result := RenderDiagnostic("select a\nfrom b", NewTokenDiagnostic(tokens[3], "unknown"), diagnosticOptions)
+
+	if result != "query.go:11:6: unknown\n11 | from b\n   |      ^" {
+		t.Errorf("Unexpected diagnostic '%s'", result)
+	}
+
+	_, err = LexerWithOptions("a\n1", tokensList, options)
+
+	lexError, ok := err.(*LexError)
+
+	if !ok || lexError.File != "query.go" || lexError.Line != 11 || lexError.Column != 1 || lexError.Offset != 202 {
+		t.Errorf("Expected error at query.go:11:1 (offset 202) found %+v", err)
+		return
+	}
+
+	if lexError.Snippet != "11 | 1\n   | ^" {
+		t.Errorf("Unexpected snippet '%s'", lexError.Snippet)
+	}
+}
+
+func Test_Lexer_Line_Directive(t *testing.T) {
+	tokensList := []TokenEntry{
+		NewRegexValueToken("LINE", "(#line [^\\n]*)", -1),
+		NewRegexValueToken("_SPACE", "(\\s)", -1),
+		NewRegexValueToken("WORD", "([a-z]+)", 1),
+	}
+
+	options := NewLexerOptions()
+	options.FileName = "out.src"
+	options.LineDirective = "LINE"
+
+	tokens, err := LexerWithOptions("a\n#line 42 \"orig.src\"\nb c\nd", tokensList, options)
+
+	if err != nil {
+		t.Errorf("Unexpected error %s", err)
+		return
+	}
+
+	if len(tokens) != 4 {
+		t.Errorf("Expected 4 tokens found %+v", tokens)
+		return
+	}
+
+	if tokens[0].File != "out.src" || tokens[0].LineNumber != 1 {
+		t.Errorf("Expected a at out.src:1 found %+v", tokens[0])
+	}
+
+	if tokens[1].File != "orig.src" || tokens[1].LineNumber != 42 || tokens[1].StartPos != 1 {
+		t.Errorf("Expected b at orig.src:42:1 found %+v", tokens[1])
+	}
+
+	if tokens[3].File != "orig.src" || tokens[3].LineNumber != 43 {
+		t.Errorf("Expected d at orig.src:43 found %+v", tokens[3])
+	}
+
+	_, err = LexerWithOptions("#line 7 \"orig.src\"\n1", tokensList, options)
+
+	lexError, ok := err.(*LexError)
+
+	if !ok || lexError.File != "orig.src" || lexError.Line != 7 {
+		t.Errorf("Expected error at orig.src:7 found %+v", err)
+	}
+}
+
+func Test_ParseLineDirective(t *testing.T) {
+	directives := []struct {
+		data string
+		file string
+		line int
+		ok   bool
+	}{
+		{"#line 42 \"orig.src\"", "orig.src", 42, true},
+		{"# 12 \"a b.c\" 1 3", "a b.c", 12, true},
+		{"#line 5", "", 5, true},
+		{"//line orig.go:10", "orig.go", 10, true},
+		{"//line orig.go:10:5", "orig.go", 10, true},
+		{"//line c:\\orig.go:10", "c:\\orig.go", 10, true},
+		{"#line x", "", 0, false},
+		{"#line 1 orig.src", "", 0, false},
+		{"//line orig.go", "", 0, false},
+	}
+
+	for _, d := range directives {
+		file, line, ok := ParseLineDirective(d.data)
+
+		if file != d.file || line != d.line || ok != d.ok {
+			t.Errorf("Directive '%s': expected %s %d %t found %s %d %t", d.data, d.file, d.line, d.ok, file, line, ok)
+		}
+	}
+}
+
+func Test_Lexer_Include(t *testing.T) {
+	tokensList := []TokenEntry{
+		NewRegexValueToken("INCLUDE", "(include \"[^\"]*\")", -1),
+		NewRegexValueToken("_SPACE", "(\\s)", -1),
+		NewRegexValueToken("WORD", "([a-z]+)", 1),
+	}
+
+	options := NewLexerOptions()
+	options.FileName = "main.conf"
+	options.Include = "INCLUDE"
+	options.IncludeFS = fstest.MapFS{
+		"conf/a.conf": {Data: []byte("b\ninclude \"c.conf\"")},
+		"conf/c.conf": {Data: []byte("c")},
+		"loop.conf":   {Data: []byte("include \"loop.conf\"")},
+	}
+
+	tokens, err := LexerWithOptions("a include \"conf/a.conf\"\nd", tokensList, options)
+
+	if err != nil {
+		t.Errorf("Unexpected error %s", err)
+		return
+	}
+
+	expected := []Token{
+		{Data: "a", File: "main.conf", LineNumber: 1, StartPos: 1},
+		{Data: "b", File: "conf/a.conf", LineNumber: 1, StartPos: 1},
+		{Data: "c", File: "conf/c.conf", LineNumber: 1, StartPos: 1},
+		{Data: "d", File: "main.conf", LineNumber: 2, StartPos: 1},
+	}
+
+	if len(tokens) != len(expected) {
+		t.Errorf("Expected %d tokens found %+v", len(expected), tokens)
+		return
+	}
+
+	for index, token := range tokens {
+		e := expected[index]
+
+		if token.Data != e.Data || token.File != e.File || token.LineNumber != e.LineNumber || token.StartPos != e.StartPos {
+			t.Errorf("Expected %s at %s:%d:%d found %+v", e.Data, e.File, e.LineNumber, e.StartPos, token)
+		}
+	}
+
+	_, err = LexerWithOptions("include \"loop.conf\"", tokensList, options)
+
+	lexError, ok := err.(*LexError)
+
+	if !ok || lexError.Message != "include cycle: main.conf -> loop.conf -> loop.conf" || lexError.File != "loop.conf" {
+		t.Errorf("Expected include cycle found %+v", err)
+	}
+
+	_, err = LexerWithOptions("a\ninclude \"missing.conf\"", tokensList, options)
+
+	lexError, ok = err.(*LexError)
+
+	if !ok || lexError.File != "main.conf" || lexError.Line != 2 || lexError.Column != 1 || lexError.Length != 22 {
+		t.Errorf("Expected error at main.conf:2:1 found %+v", err)
+	}
+
+	options.MaxIncludeDepth = 1
+
+	_, err = LexerWithOptions("include \"conf/a.conf\"", tokensList, options)
+
+	lexError, ok = err.(*LexError)
+
+	if !ok || lexError.Message != "too many nested includes (max 1)" || lexError.File != "conf/a.conf" {
+		t.Errorf("Expected too many nested includes found %+v", err)
+	}
+}
+
+func automatonTokens() []TokenEntry {
+	return []TokenEntry{
+		NewFunctionCallToken("_COMMENT", skipComment, -1),
+		NewRegexValueToken("_SPACE", "(\\s+)", -1),
+		NewHardValueToken("ASSIGN", ":=", 1),
+		NewHardValueToken("COLON", ":", 2),
+		NewHardValueToken("BEGIN", "begin", 3).WithFlags(FlagCaseInsensitive),
+		NewRegexValueToken("WORD_END", "([a-z]+\\b)", 4).WithFlags(FlagLineStart),
+		NewRegexWithSubValueToken("IDENTIFIER", "([a-zA-Zé_][a-zA-Z0-9é_]*)", []SubPattern{
+			{Name: "END", IDValue: 5, Value: "end"},
+		}, 6).WithFlags(FlagCaseInsensitive),
+		NewRegexValueToken("NUMBER", "([0-9]+(\\.[0-9]+)?)", 7),
+		NewRegexValueToken("STRING", "(\"(\\\\.|[^\"])*?\")", 8),
+		NewRegexValueToken("AB", "(a|ab)(c|bcd)", 9),
+		NewRegexValueToken("OTHER", "(.)", 10).WithFlags(FlagDotNewLine),
+	}
+}
+
+func Test_Lexer_Automaton(t *testing.T) {
+	tokensList := automatonTokens()
+	table, err := NewAutomatonTable(tokensList)
+
+	if err != nil {
+		t.Errorf("Unexpected error %s", err)
+		return
+	}
+
+	texts := []string{
+		"a := 12.5: BEGIN\nword end",
+		"abcd abc /* comment */ \"str\\\"ing\" +",
+		"Begin\n  éa_1 END\n\r\n\"not closed",
+		"\xff\xfe end\u2028x",
+	}
+
+	for _, automaton := range []*Automaton{NewAutomaton(tokensList), NewTableAutomaton(table)} {
+		options := NewLexerOptions()
+		options.Automaton = automaton
+
+		for _, text := range texts {
+			expected, expectedErr := Lexer(text, tokensList)
+			tokens, err := LexerWithOptions(text, tokensList, options)
+
+			if !reflect.DeepEqual(tokens, expected) || (err == nil) != (expectedErr == nil) {
+				t.Errorf("Text %q: expected %+v (%v) found %+v (%v)", text, expected, expectedErr, tokens, err)
+			}
+		}
+	}
+}
+
+func Test_Lexer_Token_Entry_Without_Regex(t *testing.T) {
+	// Token entry of generated lexer with automaton table
+	tokensList := []TokenEntry{
+		{Name: "NUMBER", TypeOf: RegexValue, Value: "([0-9]+)", IDValue: 1},
+		{Name: "WORD", TypeOf: RegexValue, Value: "([a-z]+)", IDValue: 2, Flags: FlagCaseInsensitive},
+	}
+
+	tokens, err := Lexer("12Ab", tokensList)
+
+	if err != nil || len(tokens) != 2 || tokens[0].Data != "12" || tokens[1].Data != "Ab" {
+		t.Errorf("Expected tokens 12 and Ab found %+v (%v)", tokens, err)
+	}
+}
+
+func Test_Lexer_Longest_Match(t *testing.T) {
+	tokensList := []TokenEntry{
+		NewRegexValueToken("_SPACE", "(\\s+)", -1),
+		NewHardValueToken("IF", "if", 1),
+		NewRegexValueToken("IDENTIFIER", "([a-z]+)", 2),
+		NewHardValueToken("LESS", "<", 3),
+		NewHardValueToken("LESS_EQUAL", "<=", 4),
+	}
+
+	options := NewLexerOptions()
+	options.LongestMatch = true
+
+	for _, automaton := range []*Automaton{nil, NewAutomaton(tokensList)} {
+		options.Automaton = automaton
+
+		tokens, err := LexerWithOptions("if ifa <= <", tokensList, options)
+
+		if err != nil {
+			t.Errorf("Unexpected error %s", err)
+			continue
+		}
+
+		names := []string{}
+
+		for _, token := range tokens {
+			names = append(names, token.Name)
+		}
+
+		if !reflect.DeepEqual(names, []string{"IF", "IDENTIFIER", "LESS_EQUAL", "LESS"}) {
+			t.Errorf("Expected IF IDENTIFIER LESS_EQUAL LESS found %+v", names)
+		}
+	}
+}
+
+func Test_Lexer_Keyword_Index(t *testing.T) {
+	tokensList := []TokenEntry{
+		NewRegexValueToken("_SPACE", "(\\s+)", -1),
+		NewHardValueToken("SHIFT_ASSIGN", "<<=", 1),
+		NewRegexValueToken("ARROW", "(<-+)", 2),
+		NewHardValueToken("SHIFT", "<<", 3),
+		NewHardValueToken("LESS", "<", 4),
+		NewHardValueToken("SELECT", "select", 5).WithFlags(FlagCaseInsensitive),
+		NewHardValueToken("STRASSE", "straße", 6).WithFlags(FlagCaseInsensitive),
+		NewRegexWithSubValueToken("IDENTIFIER", "([a-zA-Zßſ]+)", []SubPattern{
+			{Name: "FROM", IDValue: 7, Value: "from"},
+			{Name: "SKIP", IDValue: 8, Value: "skip"},
+			{Name: "OTHER_FROM", IDValue: 9, Value: "FROM"},
+		}, 10).WithFlags(FlagCaseInsensitive),
+		NewRegexWithSubValueToken("NUMBER", "([0-9]+)", []SubPattern{
+			{Name: "ZERO", IDValue: 11, Value: "0"},
+		}, 12),
+	}
+
+	tokens, err := Lexer("<<= <-- << < SeLeCt STRAßE From ſkip selecta 0 00", tokensList)
+
+	if err != nil {
+		t.Errorf("Unexpected error %s", err)
+		return
+	}
+
+	names := []string{}
+
+	for _, token := range tokens {
+		names = append(names, token.Name)
+	}
+
+	expected := []string{"SHIFT_ASSIGN", "ARROW", "SHIFT", "LESS", "SELECT", "STRASSE", "FROM", "SKIP", "SELECT", "IDENTIFIER", "ZERO", "NUMBER"}
+
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("Expected %+v found %+v", expected, names)
+	}
+}
+
+func Test_First_Bytes(t *testing.T) {
+	entries := []struct {
+		token    TokenEntry
+		expected string
+		excluded string
+	}{
+		{NewHardValueToken("IF", "if", 1), "i", "Ia"},
+		{NewHardValueToken("IF", "if", 1).WithFlags(FlagCaseInsensitive), "iI", "a"},
+		{NewRegexValueToken("NUMBER", "([0-9]+|-[0-9]+)", 1), "0123456789-", "a+"},
+		{NewRegexValueToken("WORD", "(a*b?c)", 1), "abc", "d"},
+		{NewRegexValueToken("ACCENT", "(é)", 1).WithFlags(FlagCaseInsensitive), "\xc3", "e"},
+		{NewRegexValueToken("ANY", "(.)", 1), "a\xff", "\n"},
+		{NewRegexValueToken("BOUNDARY", "(\\bx)", 1), "x", "\n"},
+	}
+
+	for _, entry := range entries {
+		set := entry.token.first
+
+		for index := 0; index < len(entry.expected); index++ {
+			if !set.contains(entry.expected[index]) {
+				t.Errorf("Token %s: expected first byte %q", entry.token.Value, entry.expected[index])
+			}
+		}
+
+		for index := 0; index < len(entry.excluded); index++ {
+			if set.contains(entry.excluded[index]) {
+				t.Errorf("Token %s: unexpected first byte %q", entry.token.Value, entry.excluded[index])
+			}
+		}
+	}
+}
+
+func Test_Source(t *testing.T) {
+	source := NewSource("ab\r\ncd\n\nef", NewlineDefault)
+
+	if source.LineCount() != 4 || source.Line(1) != "ab" || source.Line(2) != "cd" || source.Line(3) != "" || source.Line(4) != "ef" {
+		t.Errorf("Expected lines ab, cd, empty and ef found %d lines", source.LineCount())
+	}
+
+	positions := [][]int{{0, 1, 1}, {1, 1, 2}, {3, 1, 4}, {4, 2, 1}, {7, 3, 1}, {9, 4, 2}}
+
+	for _, p := range positions {
+		if line, column := source.Position(p[0]); line != p[1] || column != p[2] {
+			t.Errorf("Offset %d: expected %d:%d found %d:%d", p[0], p[1], p[2], line, column)
+		}
+	}
+
+	// Text read by part, end of line cut between two parts
+	stream := NewSource("a\r", NewlineCR|NewlineCRLF|NewlineUnicode)
+	stream.Append("\nb\xe2\x80")
+	stream.Append("\xa8c")
+
+	if stream.LineCount() != 3 || stream.Line(1) != "a" || stream.Line(2) != "b" || stream.Line(3) != "c" {
+		t.Errorf("Expected lines a, b and c found %d lines", stream.LineCount())
+	}
+
+	if line, column := stream.Position(3); line != 2 || column != 1 {
+		t.Errorf("Offset 3: expected 2:1 found %d:%d", line, column)
+	}
+
+	// Offset out of text
+	if line, column := source.Position(-1); line != 1 || column != 1 {
+		t.Errorf("Offset -1: expected 1:1 found %d:%d", line, column)
+	}
+
+	if line, column := source.Position(100); line != 4 || column != 3 {
+		t.Errorf("Offset 100: expected 4:3 found %d:%d", line, column)
+	}
+
+	// "\r\n" cut between two parts with default end of lines
+	stream = NewSource("ab\r", NewlineDefault)
+	stream.Append("\ncd")
+
+	if stream.LineCount() != 2 || stream.Line(1) != "ab" || stream.Line(2) != "cd" {
+		t.Errorf("Expected lines ab and cd found %d lines (%q)", stream.LineCount(), stream.Line(1))
+	}
+}
+
+func Test_Scanner(t *testing.T) {
+	tokensList := automatonTokens()
+	texts := []string{
+		"a := 12.5: BEGIN\nword end",
+		"abcd + \"str\" abc",
+	}
+
+	for _, text := range texts {
+		expected, expectedErr := Lexer(text, tokensList)
+
+		// Read tokens in small buffer
+		scanner := NewScanner(text, tokensList, NewLexerOptions())
+		buffer := make([]Token, 2)
+		tokens := []Token{}
+
+		for count := scanner.Read(buffer); count > 0; count = scanner.Read(buffer) {
+			tokens = append(tokens, buffer[:count]...)
+		}
+
+		if !reflect.DeepEqual(tokens, expected) || !reflect.DeepEqual(scanner.Err(), expectedErr) {
+			t.Errorf("Text %q: expected %+v (%v) found %+v (%v)", text, expected, expectedErr, tokens, scanner.Err())
+		}
+
+		// Reuse memory of previous tokens
+		tokens, err := LexerAppend(tokens[:0], text, tokensList, NewLexerOptions())
+
+		if !reflect.DeepEqual(tokens, expected) || !reflect.DeepEqual(err, expectedErr) {
+			t.Errorf("Text %q: expected %+v (%v) found %+v (%v)", text, expected, expectedErr, tokens, err)
+		}
+	}
+
+	// Tokens of included file are returned after include token
+	options := NewLexerOptions()
+	options.Include = "INCLUDE"
+	options.IncludeFS = fstest.MapFS{"a.conf": {Data: []byte("b")}}
+
+	scanner := NewScanner("include \"a.conf\" c", []TokenEntry{
+		NewRegexValueToken("INCLUDE", "(include \"[^\"]*\")", 1),
+		NewRegexValueToken("WORD", "([a-z]+)", 2),
+		NewRegexValueToken("_SPACE", "(\\s+)", SkipToken),
+	}, options)
+	names := []string{}
+
+	for token, ok := scanner.Next(); ok; token, ok = scanner.Next() {
+		names = append(names, token.Data)
+	}
+
+	if !reflect.DeepEqual(names, []string{"include \"a.conf\"", "b", "c"}) || scanner.Err() != nil {
+		t.Errorf("Expected include, b and c found %+v (%v)", names, scanner.Err())
+	}
+}
+
+func Test_Lex_Files(t *testing.T) {
+	tokensList := []TokenEntry{
+		NewRegexValueToken("_SPACE", "(\\s+)", SkipToken),
+		NewHardValueToken("ASSIGN", ":=", 1),
+		NewRegexValueToken("NUMBER", "([0-9]+)", 2),
+		NewRegexWithSubValueToken("IDENTIFIER", "([a-z]+)", []SubPattern{
+			{Name: "END", IDValue: 3, Value: "end"},
+		}, 4),
+	}
+	files := fstest.MapFS{
+		"a.src":     {Data: []byte("a := 1")},
+		"b.src":     {Data: []byte("begin\n?")},
+		"dir/c.src": {Data: []byte("end")},
+	}
+	paths := []string{"dir/c.src", "missing.src", "a.src", "b.src"}
+
+	options := NewLexerOptions()
+	options.Workers = 2
+	options.Automaton = NewAutomaton(tokensList)
+
+	results, err := LexFiles(context.Background(), files, paths, tokensList, options)
+
+	if err != nil || len(results) != len(paths) {
+		t.Errorf("Expected %d results found %d (%v)", len(paths), len(results), err)
+		return
+	}
+
+	for index, result := range results {
+		if result.Path != paths[index] {
+			t.Errorf("Result %d: expected file %s found %s", index, paths[index], result.Path)
+		}
+	}
+
+	if len(results[0].Tokens) != 1 || results[0].Tokens[0].Name != "END" || results[0].Tokens[0].File != "dir/c.src" || results[0].Err != nil {
+		t.Errorf("Expected END token in dir/c.src found %+v (%v)", results[0].Tokens, results[0].Err)
+	}
+
+	if !errors.Is(results[1].Err, fs.ErrNotExist) {
+		t.Errorf("Expected file not found error found %v", results[1].Err)
+	}
+
+	if len(results[2].Tokens) != 3 || results[2].Err != nil {
+		t.Errorf("Expected 3 tokens in a.src found %+v (%v)", results[2].Tokens, results[2].Err)
+	}
+
+	lexError, ok := results[3].Err.(*LexError)
+
+	if len(results[3].Tokens) != 1 || !ok || lexError.File != "b.src" || lexError.Line != 2 {
+		t.Errorf("Expected 1 token and error at b.src:2 found %+v (%v)", results[3].Tokens, results[3].Err)
+	}
+
+	// Files not lexed after cancel
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	results, err = LexFiles(ctx, files, paths, tokensList, options)
+
+	if err != context.Canceled {
+		t.Errorf("Expected context canceled error found %v", err)
+	}
+
+	for _, result := range results {
+		if result.Err != context.Canceled || result.Tokens != nil {
+			t.Errorf("Expected file %s not lexed found %+v (%v)", result.Path, result.Tokens, result.Err)
+		}
+	}
+}
+
+func Test_Lexer_Parallel(t *testing.T) {
+	tokensList := []TokenEntry{
+		NewRegexValueToken("_SPACE", "([ \\t]+)", SkipToken),
+		NewRegexValueToken("NEWLINE", "(\\r?\\n)", 1),
+		NewRegexValueToken("DATE", "([0-9]{4}-[0-9]{2}-[0-9]{2})", 2).WithFlags(FlagLineStart),
+		NewRegexValueToken("NUMBER", "([0-9]+)", 3),
+		NewRegexValueToken("WORD", "([a-zA-Zé]+)", 4),
+		NewRegexValueToken("STRING", "(\"(\\\\.|[^\"\\n])*\")", 5),
+	}
+	texts := []string{
+		strings.Repeat("2021-01-02 INFO \"start\" 42\r\n2021-01-03 ERROR é 7\n", 20),
+		strings.Repeat("2021-01-02 INFO ? 1\nWARN ! 2\n\n", 10),
+	}
+
+	for _, text := range texts {
+		for _, maxErrors := range []int{0, 3} {
+			for _, recovery := range []bool{false, true} {
+				options := NewLexerOptions()
+				// Lines of previous part are displayed
+				options.Diagnostic.ContextBefore = 2
+				options.ErrorRecovery = recovery
+				options.MaxErrors = maxErrors
+				options.StartLine = 10
+				options.StartColumn = 5
+				options.StartOffset = 100
+
+				expected, expectedErr := LexerWithOptions(text, tokensList, options)
+
+				options.ChunkSize = 16
+				options.Workers = 3
+
+				tokens, err := LexerParallel(text, tokensList, options)
+
+				if !reflect.DeepEqual(tokens, expected) || !reflect.DeepEqual(err, expectedErr) {
+					t.Errorf("Text %q: expected %+v (%v) found %+v (%v)", text, expected, expectedErr, tokens, err)
+				}
+			}
+		}
+	}
+
+	for _, token := range []TokenEntry{
+		NewRegexValueToken("_SPACE", "(\\s+)", SkipToken),
+		NewRegexValueToken("STRING", "(\"[^\"]*\")", 1),
+		NewRegexValueToken("LINE", "(a\\n$)", 2),
+		NewHardValueToken("AB", "a\nb", 3),
+		NewFunctionCallToken("COMMENT", skipComment, 4),
+	} {
+		if err := CheckSplitLines([]TokenEntry{token}); err == nil {
+			t.Errorf("Expected rule %s can continue after end of line", token.Name)
+		}
+	}
+
+	for _, token := range []TokenEntry{
+		NewRegexValueToken("_NEWLINE", "(\\n)", SkipToken),
+		NewRegexValueToken("LINE", "(.*\\n)", 1),
+		NewHardValueToken("AB", "ab\n", 3),
+		NewFunctionCallToken("COMMENT", skipComment, 4).WithFlags(FlagSingleLine),
+	} {
+		if err := CheckSplitLines([]TokenEntry{token}); err != nil {
+			t.Errorf("Unexpected error %s", err)
+		}
+	}
+
+	if _, err := LexerParallel("a\nb", []TokenEntry{NewRegexValueToken("_SPACE", "(\\s+)", SkipToken)}, NewLexerOptions()); err == nil {
+		t.Errorf("Expected text can't be split")
+	}
+}
+
+// Logger that keep messages
+type testLogger struct {
+	messages []string
+}
+
+func (l *testLogger) Debug(msg string, args ...interface{}) {
+	l.messages = append(l.messages, "DEBUG "+msg)
+}
+
+func (l *testLogger) Info(msg string, args ...interface{}) {
+	l.messages = append(l.messages, "INFO "+msg)
+}
+
+func (l *testLogger) Warn(msg string, args ...interface{}) {
+	l.messages = append(l.messages, "WARN "+msg)
+}
+
+func (l *testLogger) Error(msg string, args ...interface{}) {
+	l.messages = append(l.messages, "ERROR "+msg)
+}
+
+func Test_Lexer_Logger(t *testing.T) {
+	tokensList := []TokenEntry{
+		NewRegexValueToken("_SPACE", "(\\s+)", SkipToken),
+		NewRegexValueToken("WORD", "([a-z]+)", 1),
+	}
+
+	// Log disable by default
+	logger := &testLogger{}
+	options := NewLexerOptions()
+	options.Logger = logger
+
+	LexerWithOptions("a ?", tokensList, options)
+
+	if len(logger.messages) != 0 {
+		t.Errorf("Expected no messages found %+v", logger.messages)
+	}
+
+	options.LogLevel = LexerLogInfo
+
+	LexerWithOptions("a ?", tokensList, options)
+
+	if !reflect.DeepEqual(logger.messages, []string{"INFO Skip token", "ERROR 1:3: invalid token found\n1 | a ?\n  |   ^"}) {
+		t.Errorf("Expected skip token and error messages found %+v", logger.messages)
+	}
+
+	var output strings.Builder
+
+	NewLogger(&output).Info("Skip token", "method", "Lexer")
+
+	if output.String() != "[INFO] Skip token method=Lexer\n" {
+		t.Errorf("Expected '[INFO] Skip token method=Lexer' found %q", output.String())
+	}
+}
+
+// Text of demo grammar (see demo/basic.x)
+func demoTokens() ([]TokenEntry, string) {
+	tokensList := []TokenEntry{
+		NewRegexWithSubValueToken("IDENTIFIER", "([a-zA-Z]+)", []SubPattern{
+			{Name: "PRINT", IDValue: 2, Value: "print"},
+		}, 1),
+		NewHardValueToken("ADD", "+", 3),
+		NewRegexValueToken("NUMBER", "([0-9]+)", 4),
+		NewHardValueToken("EQUAL", "=", 5),
+		NewRegexValueToken("_SPACE", "(\\s)", SkipToken),
+	}
+
+	return tokensList, strings.Repeat("a = 1 + 22\nprint a + b\n", 1000)
+}
+
+// 100 rules grammar: keywords, operators and usual regex
+func hundredRulesTokens() ([]TokenEntry, string) {
+	tokensList := []TokenEntry{
+		NewFunctionCallToken("_COMMENT", skipComment, SkipToken),
+		NewRegexValueToken("_SPACE", "(\\s+)", SkipToken),
+	}
+
+	for index := 0; index < 70; index++ {
+		keyword := fmt.Sprintf("keyword%c%c", 'a'+index/26, 'a'+index%26)
+		tokensList = append(tokensList, NewHardValueToken(strings.ToUpper(keyword), keyword, len(tokensList)).WithFlags(FlagCaseInsensitive))
+	}
+
+	operators := []string{"<<=", ">>=", "<=", ">=", "==", "!=", "&&", "||", "<<", ">>", "+=", "-=", "+", "-", "*", "/", "%", "<", ">", "=", "!", "(", ")", "{"}
+
+	for _, operator := range operators {
+		tokensList = append(tokensList, NewHardValueToken("OPERATOR", operator, len(tokensList)))
+	}
+
+	tokensList = append(tokensList,
+		NewRegexValueToken("FLOAT", "([0-9]+\\.[0-9]+([eE][-+]?[0-9]+)?)", len(tokensList)),
+		NewRegexValueToken("NUMBER", "([0-9]+)", len(tokensList)+1),
+		NewRegexValueToken("STRING", "(\"(\\\\.|[^\"])*\")", len(tokensList)+2),
+		NewRegexWithSubValueToken("IDENTIFIER", "([a-zA-Z_][a-zA-Z0-9_]*)", []SubPattern{
+			{Name: "TRUE", IDValue: len(tokensList) + 3, Value: "true"},
+			{Name: "FALSE", IDValue: len(tokensList) + 4, Value: "false"},
+		}, len(tokensList)+5),
+	)
+
+	line := "keywordaa x_1 = (y + 12.5e3) * \"str\\\"ing\" /* comment */ KEYWORDCR true <<= 42 && z\n"
+
+	return tokensList, strings.Repeat(line, 500)
+}
+
+func Benchmark_Scanner_Demo(b *testing.B) {
+	tokensList, text := demoTokens()
+
+	benchmarkBackends(b, text, tokensList)
+}
+
+func Benchmark_Scanner_Hundred_Rules(b *testing.B) {
+	tokensList, text := hundredRulesTokens()
+
+	benchmarkBackends(b, text, tokensList)
+}
+
+// Text given to NewSource must not be copied, only Append copy text.
+func Benchmark_Source(b *testing.B) {
+	text := strings.Repeat("a", 1<<20) + "\n"
+
+	var before, after runtime.MemStats
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(text)))
+	b.ResetTimer()
+	runtime.ReadMemStats(&before)
+
+	for i := 0; i < b.N; i++ {
+		NewSource(text, NewlineDefault)
+	}
+
+	runtime.ReadMemStats(&after)
+
+	if copied := (after.TotalAlloc - before.TotalAlloc) / uint64(b.N); copied >= uint64(len(text)) {
+		b.Errorf("NewSource copy text: %d bytes allocated for %d bytes of text", copied, len(text))
+	}
+}
+
+func benchmarkBackends(b *testing.B, text string, tokensList []TokenEntry) {
+	table, err := NewAutomatonTable(tokensList)
+
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("Lexer", func(b *testing.B) {
+		benchmarkLexer(b, text, tokensList, NewLexerOptions(), false)
+	})
+
+	b.Run("Regexp", func(b *testing.B) {
+		benchmarkLexer(b, text, tokensList, NewLexerOptions(), true)
+	})
+
+	b.Run("Automaton", func(b *testing.B) {
+		options := NewLexerOptions()
+		options.Automaton = NewAutomaton(tokensList)
+
+		benchmarkLexer(b, text, tokensList, options, true)
+	})
+
+	b.Run("Table", func(b *testing.B) {
+		options := NewLexerOptions()
+		options.Automaton = NewTableAutomaton(table)
+
+		benchmarkLexer(b, text, tokensList, options, true)
+	})
+}
+
+// Lex text b.N times, with Lexer() or by reusing buffer of tokens, and report
+// allocations per token.
+func benchmarkLexer(b *testing.B, text string, tokensList []TokenEntry, options LexerOptions, reuse bool) {
+	tokens, err := LexerAppend(nil, text, tokensList, options)
+
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	var before, after runtime.MemStats
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(text)))
+	b.ResetTimer()
+	runtime.ReadMemStats(&before)
+
+	for i := 0; i < b.N; i++ {
+		if reuse {
+			tokens, err = LexerAppend(tokens[:0], text, tokensList, options)
+		} else {
+			tokens, err = LexerWithOptions(text, tokensList, options)
+		}
+
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	runtime.ReadMemStats(&after)
+	b.ReportMetric(float64(after.Mallocs-before.Mallocs)/float64(b.N*len(tokens)), "allocs/token")
+}
+
 func skipComment(text string, token TokenEntry) (Token, bool) {
 	lenText := len(text)
 
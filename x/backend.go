@@ -0,0 +1,140 @@
+package x
+
+// Copyright 2021 Simple Lexer Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use file except in compliance with the License.
+// You may obtain a copy of the license at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+import (
+	"fmt"
+	"slex/lexer"
+)
+
+// Name of backends
+const (
+	// BackendRegexp search tokens one by one with regex (reference)
+	BackendRegexp = "regexp"
+	// BackendAutomaton search tokens with automaton built at runtime
+	BackendAutomaton = "automaton"
+	// BackendDFA search tokens with automaton table, like generated lexer
+	BackendDFA = "dfa"
+)
+
+// Backend lex text
+type Backend = func(text string) ([]lexer.Token, error)
+
+// NewBackend create backend to lex text with token entries and options
+func NewBackend(name string, tokensList []lexer.TokenEntry, options lexer.LexerOptions) (Backend, error) {
+	switch name {
+	case BackendRegexp:
+		options.Automaton = nil
+	case BackendAutomaton:
+		options.Automaton = lexer.NewAutomaton(tokensList)
+	case BackendDFA:
+		table, err := lexer.NewAutomatonTable(tokensList)
+
+		if err != nil {
+			return nil, err
+		}
+
+		options.Automaton = lexer.NewTableAutomaton(table)
+	default:
+		return nil, fmt.Errorf("Unknown backend '%s', use regexp, automaton or dfa", name)
+	}
+
+	return func(text string) ([]lexer.Token, error) {
+		return lexer.LexerWithOptions(text, tokensList, options)
+	}, nil
+}
+
+// Divergence is first token different between two backends
+type Divergence struct {
+	// Index of token in list of tokens
+	Index int
+	// Expected is token of reference backend, nil if no token
+	Expected *lexer.Token
+	// Found is token of other backend, nil if no token
+	Found *lexer.Token
+	// ExpectedError is error of reference backend
+	ExpectedError error
+	// FoundError is error of other backend
+	FoundError error
+}
+
+func (d *Divergence) Error() string {
+	return fmt.Sprintf("token %d: expected %s, found %s", d.Index+1,
+		describeResult(d.Expected, d.ExpectedError), describeResult(d.Found, d.FoundError))
+}
+
+// Diagnostic return diagnostic at position of divergence
+func (d *Divergence) Diagnostic() lexer.Diagnostic {
+	token := d.Expected
+
+	if token == nil {
+		token = d.Found
+	}
+
+	if token == nil {
+		return lexer.Diagnostic{Message: d.Error(), Line: 1, Column: 1}
+	}
+
+	return lexer.NewTokenDiagnostic(*token, d.Error())
+}
+
+// Describe token or error of a backend.
+func describeResult(token *lexer.Token, err error) string {
+	switch {
+	case token != nil:
+		return fmt.Sprintf("%s %q at %d:%d", token.Name, token.Data, token.LineNumber, token.StartPos)
+	case err != nil:
+		return fmt.Sprintf("error '%s'", err.Error())
+	}
+
+	return "end of text"
+}
+
+// CompareBackends lex text with reference and other backend. Return first
+// token different, nil if tokens and errors are same.
+func CompareBackends(text string, reference Backend, other Backend) *Divergence {
+	expectedTokens, expectedErr := reference(text)
+	foundTokens, foundErr := other(text)
+
+	for index := 0; index < len(expectedTokens) || index < len(foundTokens); index++ {
+		d := &Divergence{Index: index}
+
+		if index < len(expectedTokens) {
+			d.Expected = &expectedTokens[index]
+		} else {
+			d.ExpectedError = expectedErr
+		}
+
+		if index < len(foundTokens) {
+			d.Found = &foundTokens[index]
+		} else {
+			d.FoundError = foundErr
+		}
+
+		if d.Expected == nil || d.Found == nil || *d.Expected != *d.Found {
+			return d
+		}
+	}
+
+	if (expectedErr == nil) != (foundErr == nil) || (expectedErr != nil && expectedErr.Error() != foundErr.Error()) {
+		return &Divergence{
+			Index:         len(expectedTokens),
+			ExpectedError: expectedErr,
+			FoundError:    foundErr,
+		}
+	}
+
+	return nil
+}
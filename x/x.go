@@ -17,6 +17,7 @@ package x
 import (
 	"fmt"
 	"regexp"
+	"regexp/syntax"
 	"slex/lexer"
 	"strings"
 )
@@ -32,18 +33,139 @@ const (
 
 var spaceSplitRegex = regexp.MustCompile("\\s")
 
+// Flag of token entry
+type flag struct {
+	// Name of flag in lexer package, to generate code
+	name string
+	// Value of flag, to create token entry at runtime
+	value int
+}
+
+// Flags can be add after identifier, separate by '/' (e.g. SECTION/b)
+var flagsTable = map[rune]flag{
+	'b': {"FlagLineStart", lexer.FlagLineStart},
+	'f': {"FlagFirstNonBlank", lexer.FlagFirstNonBlank},
+	'i': {"FlagCaseInsensitive", lexer.FlagCaseInsensitive},
+	's': {"FlagDotNewLine", lexer.FlagDotNewLine},
+	'm': {"FlagMultiLine", lexer.FlagMultiLine},
+	'l': {"FlagSingleLine", lexer.FlagSingleLine},
+}
+
+// Severity of SpecError
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Code of SpecError
+const (
+	CodeContinueLine     = "X001"
+	CodeMissingSymbol    = "X002"
+	CodeMissingValue     = "X003"
+	CodeUnknownType      = "X004"
+	CodeUnknownFlag      = "X005"
+	CodeInvalidRegex     = "X006"
+	CodeRegexMatchEmpty  = "X007"
+	CodeSubParameters    = "X008"
+	CodeUnknownFunction  = "X009"
+	CodeRegexAlternation = "X010"
+)
+
+// SpecError is error or warning found in lexer file
+type SpecError struct {
+	// Severity is SeverityError or SeverityWarning
+	Severity string
+	// Code of error (CodeXXX)
+	Code string
+	// Line of error in lexer file
+	Line int
+	// Column of error in lexer file
+	Column int
+	// Length of part of line in error
+	Length int
+	// Message of error
+	Message string
+	// Fix is suggested fix, can be empty
+	Fix string
+}
+
+func (e *SpecError) Error() string {
+	return e.Message
+}
+
+// Create a SpecError about token found in line of lexer file.
+func newSpecError(line lexer.Token, token lexer.Token, code string, format string, a ...interface{}) *SpecError {
+	return &SpecError{
+		Severity: SeverityError,
+		Code:     code,
+		Line:     line.LineNumber,
+		Column:   line.StartPos + token.StartPos - 1,
+		Length:   token.Lenght,
+		Message:  fmt.Sprintf(format, a...),
+	}
+}
+
+// A rule is a line of lexer file
+type rule struct {
+	// line of lexer file
+	line lexer.Token
+	// id is identifier of token
+	id string
+	// flags is letter of flags
+	flags string
+	// typeOf is "==", "~=" or "=>"
+	typeOf string
+	// value is hard value, regex or function name
+	value string
+	// fn is function name of regex
+	fn string
+	// subPatterns is list of identifier/value of regex
+	subPatterns [][]string
+}
+
+// Return true if data of token is skip.
+func (r *rule) isSkip() bool {
+	return strings.HasPrefix(r.id, "_")
+}
+
 // ParseParameters convert parameter in file into parameter code
 func ParseParameters(data string, packageName string) (string, error) {
-	filterTokens, errFilter := filterComment(data)
+	rules, err := parseRules(data)
 
-	if errFilter != nil {
-		return "", errFilter
+	if err != nil {
+		return "", err
 	}
 
-	tokens, errMerge := mergeContinueLine(filterTokens)
+	if len(packageName) > 0 {
+		packageName = packageName + "."
+	}
 
-	if errMerge != nil {
-		return "", errMerge
+	result := []string{fmt.Sprintf("[]%sTokenEntry{", packageName)}
+
+	for _, r := range rules {
+		result = append(result, generateOneLine(r, packageName))
+	}
+
+	result = append(result, "}", "") // Empty string to have return line at end
+
+	return strings.Join(result, "\n"), nil
+}
+
+// ParseParametersDFA convert parameter in file into parameter code and code of
+// automaton table (SlexAutomaton variable) to add in lexer file. Regex searched
+// by automaton are not compiled at runtime.
+func ParseParametersDFA(data string, packageName string) (string, string, error) {
+	rules, err := parseRules(data)
+
+	if err != nil {
+		return "", "", err
+	}
+
+	entries, _ := newTokenEntries(rules, nil)
+	table, err := lexer.NewAutomatonTable(entries)
+
+	if err != nil {
+		return "", "", err
 	}
 
 	if len(packageName) > 0 {
@@ -52,25 +174,163 @@ func ParseParameters(data string, packageName string) (string, error) {
 
 	result := []string{fmt.Sprintf("[]%sTokenEntry{", packageName)}
 
+	for index, r := range rules {
+		if r.typeOf == "~=" && table.Tokens[index] {
+			result = append(result, generateOneStruct(r, packageName))
+		} else {
+			result = append(result, generateOneLine(r, packageName))
+		}
+	}
+
+	result = append(result, "}", "")
+
+	return strings.Join(result, "\n"), generateAutomaton(table), nil
+}
+
+// ParseTokenEntries convert parameter in file into token entries, to lex text
+// at runtime. IDValue of token are given in order of identifier found.
+// Function are search in callbacks. If function not found, token never match
+// and a warning is returned.
+func ParseTokenEntries(data string, callbacks map[string]lexer.TokenCallback) ([]lexer.TokenEntry, []*SpecError, error) {
+	rules, err := parseRules(data)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	entries, warnings := newTokenEntries(rules, callbacks)
+
+	return entries, warnings, nil
+}
+
+// Create token entries of rules.
+func newTokenEntries(rules []rule, callbacks map[string]lexer.TokenCallback) ([]lexer.TokenEntry, []*SpecError) {
+	ids := map[string]int{}
+	idValue := func(id string) int {
+		if strings.HasPrefix(id, "_") {
+			return lexer.SkipToken
+		}
+
+		if _, found := ids[id]; !found {
+			ids[id] = len(ids) + 1
+		}
+
+		return ids[id]
+	}
+	callback := func(r rule, name string, warnings []*SpecError) (lexer.TokenCallback, []*SpecError) {
+		if fn, found := callbacks[name]; found {
+			return fn, warnings
+		}
+
+		return neverMatch, append(warnings, &SpecError{
+			Severity: SeverityWarning,
+			Code:     CodeUnknownFunction,
+			Line:     r.line.LineNumber,
+			Column:   r.line.StartPos,
+			Length:   len(r.line.Data),
+			Message:  fmt.Sprintf("Function '%s' of '%s' not found, token never match", name, r.id),
+		})
+	}
+
+	entries := []lexer.TokenEntry{}
+	warnings := []*SpecError{}
+
+	for _, r := range rules {
+		var entry lexer.TokenEntry
+		var fn lexer.TokenCallback
+		id := idValue(r.id)
+
+		if r.typeOf == "~=" && isTopLevelAlternation(r.value) {
+			warnings = append(warnings, &SpecError{
+				Severity: SeverityWarning,
+				Code:     CodeRegexAlternation,
+				Line:     r.line.LineNumber,
+				Column:   r.line.StartPos,
+				Length:   len(r.line.Data),
+				Message:  fmt.Sprintf("Regex '%s' of '%s' has alternation outside parentheses", r.value, r.id),
+				Fix:      fmt.Sprintf("enclose regex in parentheses: (%s)", r.value),
+			})
+		}
+
+		switch {
+		case r.typeOf == "=>":
+			fn, warnings = callback(r, r.value, warnings)
+			entry = lexer.NewFunctionCallToken(r.id, fn, id)
+		case r.typeOf == "==":
+			entry = lexer.NewHardValueToken(r.id, r.value, id)
+		case r.fn != "":
+			fn, warnings = callback(r, r.fn, warnings)
+			entry = lexer.NewRegexWithSubValueFnToken(r.id, r.value, fn, id)
+		case r.subPatterns != nil:
+			subPatterns := []lexer.SubPattern{}
+
+			for _, subPattern := range r.subPatterns {
+				subPatterns = append(subPatterns, lexer.SubPattern{
+					Name:    subPattern[0],
+					IDValue: idValue(subPattern[0]),
+					Value:   subPattern[1],
+				})
+			}
+
+			entry = lexer.NewRegexWithSubValueToken(r.id, r.value, subPatterns, id)
+		default:
+			entry = lexer.NewRegexValueToken(r.id, r.value, id)
+		}
+
+		flags := 0
+
+		for _, f := range r.flags {
+			flags |= flagsTable[f].value
+		}
+
+		if flags != 0 {
+			entry = entry.WithFlags(flags)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, warnings
+}
+
+// Callback of function not found.
+func neverMatch(text string, token lexer.TokenEntry) (lexer.Token, bool) {
+	return lexer.Token{}, false
+}
+
+// Parse all rules of lexer file.
+func parseRules(data string) ([]rule, error) {
+	filterTokens, errFilter := filterComment(data)
+
+	if errFilter != nil {
+		return nil, errFilter
+	}
+
+	tokens, errMerge := mergeContinueLine(filterTokens)
+
+	if errMerge != nil {
+		return nil, errMerge
+	}
+
+	rules := []rule{}
+
 	for _, token := range tokens {
 		lineTokens, errLine := parseOneLine(token)
 
 		if errLine != nil {
-			return "", errLine
+			return nil, errLine
 		}
 
-		line, errGenerate := generateOneLine(lineTokens, packageName)
+		r, errRule := parseRule(lineTokens, token)
 
-		if errGenerate != nil {
-			return "", errGenerate
+		if errRule != nil {
+			return nil, errRule
 		}
 
-		result = append(result, line)
+		rules = append(rules, r)
 	}
 
-	result = append(result, "}", "") // Empty string to have return line at end
-
-	return strings.Join(result, "\n"), nil
+	return rules, nil
 }
 
 // Remove all comments
@@ -101,7 +361,10 @@ func mergeContinueLine(tokens []lexer.Token) ([]lexer.Token, error) {
 
 			// Check if last item to avoid error
 			if tokenCount <= index+1 {
-				return nil, fmt.Errorf("Continue line '\\' without newline")
+				err := newSpecError(currentToken, lexer.Token{StartPos: len(currentToken.Data), Lenght: 1}, CodeContinueLine, "Continue line '\\' without newline")
+				err.Fix = "remove '\\' at end of line"
+
+				return nil, err
 			}
 
 			currentToken.Data = currentToken.Data + tokens[index+1].Data
@@ -121,7 +384,7 @@ func mergeContinueLine(tokens []lexer.Token) ([]lexer.Token, error) {
 func parseOneLine(token lexer.Token) ([]lexer.Token, error) {
 	tokensList := []lexer.TokenEntry{
 		lexer.NewRegexValueToken("_SPACE", "(\\s)", -1),
-		lexer.NewRegexValueToken("IDENTIFIANT", "([a-zA-Z_0-9.]+)", idToken),
+		lexer.NewRegexValueToken("IDENTIFIANT", "([a-zA-Z_0-9.]+(/[a-zA-Z]+)?)", idToken),
 		lexer.NewHardValueToken("HARD_VALUE", "==", hardValueToken),
 		lexer.NewHardValueToken("REGEX_VALUE", "~=", regexValueToken),
 		lexer.NewHardValueToken("FN_CALL", "=>", functionCallToken),
@@ -142,82 +405,284 @@ func update(slice []lexer.Token, t lexer.Token, index int) []lexer.Token {
 	return append(newToken, slice[index+1:]...)
 }
 
-// Generate the string of one line in input file to output file.
-func generateOneLine(tokens []lexer.Token, packageName string) (string, error) {
+// Parse tokens of one line in input file.
+func parseRule(tokens []lexer.Token, line lexer.Token) (rule, error) {
 	if len(tokens) < 2 {
-		return "", fmt.Errorf("Synthaxe error, missing symbol after '%s'", tokens[0].Data)
+		return rule{}, newSpecError(line, tokens[0], CodeMissingSymbol, "Synthaxe error, missing symbol after '%s'", tokens[0].Data)
 	} else if len(tokens) < 3 {
-		return "", fmt.Errorf("Synthaxe error, missing value after '%s%s'", tokens[0].Data, tokens[1].Data)
+		return rule{}, newSpecError(line, tokens[1], CodeMissingValue, "Synthaxe error, missing value after '%s%s'", tokens[0].Data, tokens[1].Data)
 	}
 
-	id := tokens[0].Data
-	typeOf := tokens[1].Data
-	var num string
-	var value string
-	var fn string
-	var extra string
+	r := rule{
+		line:   line,
+		id:     tokens[0].Data,
+		typeOf: tokens[1].Data,
+		value:  tokens[2].Data,
+	}
 
-	// If token id start by underscore, we add special value to ignore it
-	if strings.HasPrefix(id, "_") {
-		num = "-1"
-	} else {
-		num = id
+	if pos := strings.Index(r.id, "/"); pos != -1 {
+		r.id, r.flags = r.id[:pos], r.id[pos+1:]
+
+		for _, f := range r.flags {
+			if _, found := flagsTable[f]; !found {
+				err := newSpecError(line, tokens[0], CodeUnknownFlag, "Unknown flag '%c' for '%s'", f, r.id)
+				err.Fix = "use flags b, f, i, s, m or l"
+
+				return rule{}, err
+			}
+		}
 	}
 
-	switch typeOf {
-	case "=>":
-		fn = fmt.Sprintf("%sNewFunctionCallToken", packageName)
-		value = tokens[2].Data
-		extra = ""
-	case "==":
-		fn = fmt.Sprintf("%sNewHardValueToken", packageName)
-		value = fmt.Sprintf("\"%s\"", escapeString(tokens[2].Data))
-		extra = ""
+	switch r.typeOf {
+	case "=>", "==":
 	case "~=":
 		datas := splitData(tokens[2].Data)
-		value = fmt.Sprintf("\"%s\"", escapeString(datas[0]))
+		r.value = datas[0]
+
+		if errRegex := checkRegex(r.id, r.value); errRegex != nil {
+			err := newSpecError(line, tokens[2], CodeInvalidRegex, "%s", errRegex.Error())
+
+			if errRegex == errMatchEmpty {
+				err.Code = CodeRegexMatchEmpty
+				err.Message = fmt.Sprintf("Regex '%s' for '%s' can match empty string", r.value, r.id)
+				err.Fix = "regex must match at least one character (e.g. replace '*' by '+')"
+			}
+
+			return rule{}, err
+		}
 
 		if len(datas) == 1 {
-			fn = fmt.Sprintf("%sNewRegexValueToken", packageName)
-			extra = ""
+			// Only regex
 		} else if strings.Index(datas[1], "=") == -1 {
 			// Check if = sign is found.
 			// If not found, this is a line with function to call
-			fn = fmt.Sprintf("%sNewRegexWithSubValueFnToken", packageName)
-			extra = fmt.Sprintf("%s, ", datas[1])
+			r.fn = datas[1]
 		} else {
-			fn = fmt.Sprintf("%sNewRegexWithSubValueToken", packageName)
-
-			extras := []string{"", fmt.Sprintf("\t\t[]%sSubPattern{", packageName)}
-
 			subParamsTokens, err := parseSubParameters(datas[1])
 
 			if err != nil {
-				return "", fmt.Errorf("Error when parse sub parameters")
+				return rule{}, newSpecError(line, tokens[2], CodeSubParameters, "Error when parse sub parameters")
 			}
 
-			subParameters, errGenerate := generateSubParameters(subParamsTokens)
+			subPatterns, errSub := parseSubPatterns(subParamsTokens)
 
-			if errGenerate != nil {
-				return "", errGenerate
+			if errSub != nil {
+				return rule{}, newSpecError(line, tokens[2], CodeSubParameters, "%s", errSub.Error())
 			}
 
-			extras = append(extras, subParameters...)
-			extras = append(extras, "\t\t},", "\t\t")
+			r.subPatterns = subPatterns
+		}
+	default:
+		return rule{}, newSpecError(line, tokens[1], CodeUnknownType, "Synthaxe error, unknown type '%s'", r.typeOf)
+	}
 
-			extra = strings.Join(extras, "\n")
+	return r, nil
+}
 
-			num += ",\n\t"
-		}
+// Generate the string of one line in input file to output file.
+func generateOneLine(r rule, packageName string) string {
+	var num string
+	var value string
+	var fn string
+	var extra string
+	var flags string
+
+	// If token id start by underscore, we add special value to ignore it
+	if r.isSkip() {
+		num = "-1"
+	} else {
+		num = r.id
+	}
 
+	switch {
+	case r.typeOf == "=>":
+		fn = fmt.Sprintf("%sNewFunctionCallToken", packageName)
+		value = r.value
+	case r.typeOf == "==":
+		fn = fmt.Sprintf("%sNewHardValueToken", packageName)
+		value = fmt.Sprintf("\"%s\"", escapeString(r.value))
+	case r.fn != "":
+		fn = fmt.Sprintf("%sNewRegexWithSubValueFnToken", packageName)
+		value = fmt.Sprintf("\"%s\"", escapeString(r.value))
+		extra = fmt.Sprintf("%s, ", r.fn)
+	case r.subPatterns != nil:
+		fn = fmt.Sprintf("%sNewRegexWithSubValueToken", packageName)
+		value = fmt.Sprintf("\"%s\"", escapeString(r.value))
+
+		extras := []string{"", fmt.Sprintf("\t\t[]%sSubPattern{", packageName)}
+		extras = append(extras, generateSubParameters(r.subPatterns)...)
+		extras = append(extras, "\t\t},", "\t\t")
+
+		extra = strings.Join(extras, "\n")
+
+		num += ",\n\t"
 	default:
-		fn = "???"
-		value = "???"
+		fn = fmt.Sprintf("%sNewRegexValueToken", packageName)
+		value = fmt.Sprintf("\"%s\"", escapeString(r.value))
+	}
+
+	if r.flags != "" {
+		flags = fmt.Sprintf(".WithFlags(%s)", flagsCode(r.flags, packageName))
 	}
 
 	return fmt.Sprintf(
-		"\t%s(\"%s\", %s, %s%s),",
-		fn, id, value, extra, num), nil
+		"\t%s(\"%s\", %s, %s%s)%s,",
+		fn, r.id, value, extra, num, flags)
+}
+
+// Generate token entry of regex without constructor, to not compile regex.
+func generateOneStruct(r rule, packageName string) string {
+	num := r.id
+
+	if r.isSkip() {
+		num = "-1"
+	}
+
+	fields := []string{
+		fmt.Sprintf("Name: \"%s\"", r.id),
+		fmt.Sprintf("TypeOf: %sRegexValue", packageName),
+		fmt.Sprintf("Value: \"%s\"", escapeString(r.value)),
+	}
+
+	if r.fn != "" {
+		fields = append(fields, fmt.Sprintf("FnCallback: %s", r.fn))
+	}
+
+	if r.subPatterns != nil {
+		subPatterns := []string{fmt.Sprintf("SubValue: []%sSubPattern{", packageName)}
+		subPatterns = append(subPatterns, generateSubParameters(r.subPatterns)...)
+		subPatterns = append(subPatterns, "\t\t}")
+
+		fields = append(fields, strings.Join(subPatterns, "\n"))
+	}
+
+	fields = append(fields, fmt.Sprintf("IDValue: %s", num))
+
+	if r.flags != "" {
+		fields = append(fields, fmt.Sprintf("Flags: %s", flagsCode(r.flags, packageName)))
+	}
+
+	return fmt.Sprintf("\t{%s},", strings.Join(fields, ", "))
+}
+
+// Return code of flags (e.g. lexer.FlagLineStart|lexer.FlagMultiLine)
+func flagsCode(flags string, packageName string) string {
+	names := []string{}
+
+	for _, f := range flags {
+		names = append(names, packageName+flagsTable[f].name)
+	}
+
+	return strings.Join(names, "|")
+}
+
+// Generate code of automaton table.
+func generateAutomaton(table lexer.AutomatonTable) string {
+	tokens := []string{}
+
+	for _, token := range table.Tokens {
+		tokens = append(tokens, fmt.Sprintf("%t", token))
+	}
+
+	result := []string{
+		"",
+		"// SlexAutomaton search tokens without regex (generated by slex)",
+		"var SlexAutomaton = NewTableAutomaton(AutomatonTable{",
+		fmt.Sprintf("\tTokens: []bool{%s},", strings.Join(tokens, ", ")),
+		"\tMatches: [][]int{",
+	}
+
+	for _, matches := range table.Matches {
+		if len(matches) == 0 {
+			result = append(result, "\t\tnil,")
+			continue
+		}
+
+		values := []string{}
+
+		for _, match := range matches {
+			values = append(values, fmt.Sprintf("%d", match))
+		}
+
+		result = append(result, fmt.Sprintf("\t\t{%s},", strings.Join(values, ", ")))
+	}
+
+	result = append(result, "\t},", "\tTransitions: [][]AutomatonTransition{")
+
+	for _, transitions := range table.Transitions {
+		values := []string{}
+
+		for _, transition := range transitions {
+			values = append(values, fmt.Sprintf("{%d, %d, %d}", transition.Low, transition.High, transition.Next))
+		}
+
+		result = append(result, fmt.Sprintf("\t\t{%s},", strings.Join(values, ", ")))
+	}
+
+	result = append(result, "\t},", "})", "")
+
+	return strings.Join(result, "\n")
+}
+
+// Error return by checkRegex when regex can match empty string
+var errMatchEmpty = fmt.Errorf("regex can match empty string")
+
+// Check if regex is valid and can't match empty string (infinite loop in lexer).
+func checkRegex(id string, value string) error {
+	re, err := syntax.Parse(value, syntax.Perl)
+
+	if err != nil {
+		return fmt.Errorf("Invalid regex '%s' for '%s': %s", value, id, err.Error())
+	}
+
+	if matchEmpty(re) {
+		return errMatchEmpty
+	}
+
+	return nil
+}
+
+// Return true if regex is an alternation without parentheses (e.g. ab|c).
+func isTopLevelAlternation(value string) bool {
+	re, err := syntax.Parse(value, syntax.Perl)
+
+	return err == nil && re.Op == syntax.OpAlternate
+}
+
+// Return true if regex can match empty string.
+func matchEmpty(re *syntax.Regexp) bool {
+	switch re.Op {
+	case syntax.OpEmptyMatch, syntax.OpBeginLine, syntax.OpEndLine,
+		syntax.OpBeginText, syntax.OpEndText,
+		syntax.OpWordBoundary, syntax.OpNoWordBoundary,
+		syntax.OpStar, syntax.OpQuest:
+		return true
+	case syntax.OpLiteral:
+		return len(re.Rune) == 0
+	case syntax.OpCapture, syntax.OpPlus:
+		return matchEmpty(re.Sub[0])
+	case syntax.OpRepeat:
+		return re.Min == 0 || matchEmpty(re.Sub[0])
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			if !matchEmpty(sub) {
+				return false
+			}
+		}
+
+		return true
+	case syntax.OpAlternate:
+		for _, sub := range re.Sub {
+			if matchEmpty(sub) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	// OpNoMatch, OpCharClass, OpAnyChar, OpAnyCharNotNL
+	return false
 }
 
 // A line with sub parameter A=x B=y ... to be convert into token.
@@ -232,29 +697,37 @@ func parseSubParameters(data string) ([]lexer.Token, error) {
 	return lexer.Lexer(data, tokensList)
 }
 
-// Take a list of token that represent a sub parameter
-func generateSubParameters(tokens []lexer.Token) ([]string, error) {
+// Take a list of token that represent a sub parameter and return list of
+// identifier/value
+func parseSubPatterns(tokens []lexer.Token) ([][]string, error) {
 	// A value is always 3 item
 	lenOfTokens := len(tokens)
-	var id string
-	var value string
-	result := []string{}
+	result := [][]string{}
 
 	for index := 0; index < lenOfTokens; index += 2 {
-		id = tokens[index].Data
+		id := tokens[index].Data
 
 		if lenOfTokens <= index+1 {
 			return nil, fmt.Errorf("Syntax error. Missing value of sub parameter '%s'", id)
 		}
 
-		value = tokens[index+1].Data
-
-		result = append(result, fmt.Sprintf("\t\t\t{\"%s\", %s, \"%s\"},", id, id, value))
+		result = append(result, []string{id, tokens[index+1].Data})
 	}
 
 	return result, nil
 }
 
+// Generate code of sub parameters
+func generateSubParameters(subPatterns [][]string) []string {
+	result := []string{}
+
+	for _, subPattern := range subPatterns {
+		result = append(result, fmt.Sprintf("\t\t\t{\"%s\", %s, \"%s\"},", subPattern[0], subPattern[0], subPattern[1]))
+	}
+
+	return result
+}
+
 func escapeString(data string) string {
 	data = strings.Replace(data, "\\", "\\\\", -1)
 	return strings.Replace(data, "\"", "\\\"", -1)
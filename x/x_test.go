@@ -15,6 +15,8 @@ package x
 // limitations under the License.
 
 import (
+	"errors"
+	"slex/lexer"
 	"testing"
 
 	"github.com/andreyvit/diff"
@@ -93,6 +95,34 @@ _NEWLINE            ~= (\n|\r|\r\n)
 	}
 }
 
+func Test_Generate_With_Flags(t *testing.T) {
+	data := `SECTION/b ~= (\[[a-z]+\])
+DIRECTIVE/bf == #
+SELECT/i == select
+`
+	dataToGet := `[]x.TokenEntry{
+	x.NewRegexValueToken("SECTION", "(\\[[a-z]+\\])", SECTION).WithFlags(x.FlagLineStart),
+	x.NewHardValueToken("DIRECTIVE", "#", DIRECTIVE).WithFlags(x.FlagLineStart|x.FlagFirstNonBlank),
+	x.NewHardValueToken("SELECT", "select", SELECT).WithFlags(x.FlagCaseInsensitive),
+}
+`
+	dataToWriteInFile, err := ParseParameters(data, "x")
+
+	if err != nil {
+		t.Errorf(err.Error())
+	} else if dataToWriteInFile != dataToGet {
+		t.Errorf("Result not as expected:\n%v", diff.LineDiff(dataToWriteInFile, dataToGet))
+	}
+}
+
+func Test_Error_Unknown_Flag(t *testing.T) {
+	_, err := ParseParameters("SECTION/z == [", "")
+
+	if err == nil || err.Error() != "Unknown flag 'z' for 'SECTION'" {
+		t.Error("No error when flag is unknown")
+	}
+}
+
 func Test_Errors_MultiLine(t *testing.T) {
 	_, err := ParseParameters("A=1 \\", "")
 
@@ -124,3 +154,221 @@ func Test_Error_Missing_SubValue(t *testing.T) {
 		t.Error("No error when not found sub value")
 	}
 }
+
+func Test_Error_Regex_Match_Empty(t *testing.T) {
+	_, err := ParseParameters("_WS ~= (\\s*)", "")
+
+	if err == nil || err.Error() != "Regex '(\\s*)' for '_WS' can match empty string" {
+		t.Errorf("No error when regex match empty string: %v", err)
+	}
+}
+
+func Test_Error_Invalid_Regex(t *testing.T) {
+	_, err := ParseParameters("A ~= ([a-z]", "")
+
+	if err == nil || err.Error() != "Invalid regex '([a-z]' for 'A': error parsing regexp: missing closing ): `([a-z]`" {
+		t.Errorf("No error when regex is invalid: %v", err)
+	}
+}
+
+func Test_Error_Position(t *testing.T) {
+	_, err := ParseParameters("// Comment\nA == a\nSECTION/z == [", "")
+
+	var specError *SpecError
+
+	if !errors.As(err, &specError) {
+		t.Errorf("Error is not a SpecError: %+v", err)
+		return
+	}
+
+	if specError.Code != CodeUnknownFlag || specError.Line != 3 || specError.Column != 1 || specError.Length != 9 || specError.Fix == "" {
+		t.Errorf("Expected {Code:X005 Line:3 Column:1 Length:9} found %+v", specError)
+	}
+}
+
+func Test_Parse_Token_Entries(t *testing.T) {
+	data := `_SPACE ~= (\s)
+_COMMENT => skipComment
+IDENTIFIER/i ~= ([a-z]+)	PRINT=print
+NUMBER ~= ([0-9]+)
+`
+	entries, warnings, err := ParseTokenEntries(data, nil)
+
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	if len(warnings) != 1 || warnings[0].Code != CodeUnknownFunction || warnings[0].Line != 2 {
+		t.Errorf("Expected one warning about skipComment found %+v", warnings)
+	}
+
+	tokens, errLexer := lexer.Lexer("PRINT 12", entries)
+
+	if errLexer != nil {
+		t.Error(errLexer.Error())
+		return
+	}
+
+	if len(tokens) != 2 || tokens[0].Name != "PRINT" || tokens[0].IDValue != 2 || tokens[1].Name != "NUMBER" || tokens[1].IDValue != 3 {
+		t.Errorf("Expected tokens PRINT and NUMBER found %+v", tokens)
+	}
+}
+
+func Test_Generate_DFA(t *testing.T) {
+	data := `ADD == +
+WORD ~= (x\b)
+NAME/i ~= ([a-z]+)	END=end
+DASH ~= (-+)	countDash
+`
+	dataToGet := `[]lexer.TokenEntry{
+	lexer.NewHardValueToken("ADD", "+", ADD),
+	lexer.NewRegexValueToken("WORD", "(x\\b)", WORD),
+	{Name: "NAME", TypeOf: lexer.RegexValue, Value: "([a-z]+)", SubValue: []lexer.SubPattern{
+			{"END", END, "end"},
+		}, IDValue: NAME, Flags: lexer.FlagCaseInsensitive},
+	{Name: "DASH", TypeOf: lexer.RegexValue, Value: "(-+)", FnCallback: countDash, IDValue: DASH},
+}
+`
+	automatonToGet := `
+// SlexAutomaton search tokens without regex (generated by slex)
+var SlexAutomaton = NewTableAutomaton(AutomatonTable{
+	Tokens: []bool{true, false, true, true},
+	Matches: [][]int{
+		nil,
+		{0},
+		{3},
+		{2},
+	},
+	Transitions: [][]AutomatonTransition{
+		{{43, 43, 1}, {45, 45, 2}, {65, 90, 3}, {97, 122, 3}, {383, 383, 3}, {8490, 8490, 3}},
+		{},
+		{{45, 45, 2}},
+		{{65, 90, 3}, {97, 122, 3}, {383, 383, 3}, {8490, 8490, 3}},
+	},
+})
+`
+	dataToWriteInFile, automaton, err := ParseParametersDFA(data, "lexer")
+
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	if dataToWriteInFile != dataToGet {
+		t.Errorf("Result not as expected:\n%v", diff.LineDiff(dataToWriteInFile, dataToGet))
+	}
+
+	if automaton != automatonToGet {
+		t.Errorf("Automaton not as expected:\n%v", diff.LineDiff(automaton, automatonToGet))
+	}
+}
+
+// Lexer file of differential tests of backends
+const backendsSpec = `_SPACE ~= (\s+)
+ASSIGN == :=
+COLON == :
+BEGIN/i == begin
+WORD/b ~= ([a-z]+\b)
+IDENTIFIER/i ~= ([a-zé_][a-z0-9é_]*)	END=end
+NUMBER ~= ([0-9]+(\.[0-9]+)?)
+STRING ~= ("(\\.|[^"])*?")
+AB ~= ((a|ab)(c|bcd))
+`
+
+func newTestBackends(t testing.TB, spec string) []Backend {
+	tokensList, _, err := ParseTokenEntries(spec, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	options := lexer.NewLexerOptions()
+	options.ErrorRecovery = true
+	backends := []Backend{}
+
+	for _, name := range []string{BackendRegexp, BackendAutomaton, BackendDFA} {
+		backend, err := NewBackend(name, tokensList, options)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		backends = append(backends, backend)
+	}
+
+	return backends
+}
+
+func Test_Compare_Backends(t *testing.T) {
+	backends := newTestBackends(t, backendsSpec)
+	text := "a := 12.5: BEGIN\nword end \"str\\\"ing\" abcd é ?"
+
+	for _, backend := range backends[1:] {
+		if d := CompareBackends(text, backends[0], backend); d != nil {
+			t.Errorf("Unexpected divergence %s", d)
+		}
+	}
+
+	other := func(text string) ([]lexer.Token, error) {
+		tokens, err := backends[0](text)
+		tokens[1].Name = "OTHER"
+
+		return tokens, err
+	}
+
+	d := CompareBackends(text, backends[0], other)
+
+	if d == nil || d.Error() != `token 2: expected ASSIGN ":=" at 1:3, found OTHER ":=" at 1:3` {
+		t.Errorf("Expected divergence at token 2 found %v", d)
+	}
+
+	if d != nil && d.Diagnostic().Offset != 2 {
+		t.Errorf("Expected divergence at offset 2 found %+v", d.Diagnostic())
+	}
+}
+
+func Test_Compare_Backends_Alternation(t *testing.T) {
+	// Each branch of alternation without parentheses start at token position
+	spec := `W ~= [a]x|b
+A ~= [a]
+C/i ~= [x]y|z
+`
+	backends := newTestBackends(t, spec)
+	text := "abZaxb"
+
+	tokens, err := backends[0](text)
+
+	if err != nil || len(tokens) != 5 || tokens[0].Name != "A" || tokens[1].Name != "W" || tokens[2].Name != "C" || tokens[3].Data != "ax" {
+		t.Errorf("Expected tokens A, W, C, W and W found %+v (%v)", tokens, err)
+	}
+
+	for _, backend := range backends[1:] {
+		if d := CompareBackends(text, backends[0], backend); d != nil {
+			t.Errorf("Unexpected divergence %s", d)
+		}
+	}
+
+	_, warnings, _ := ParseTokenEntries(spec, nil)
+
+	if len(warnings) != 2 || warnings[0].Code != CodeRegexAlternation || warnings[0].Line != 1 {
+		t.Errorf("Expected warnings about alternation found %+v", warnings)
+	}
+}
+
+func FuzzBackends(f *testing.F) {
+	backends := newTestBackends(f, backendsSpec)
+
+	f.Add("a := 12.5: BEGIN\nword end")
+	f.Add("abcd abc \"str\\\"ing\" +")
+	f.Add("Begin\n  éa_1 END\n\r\n\"not closed")
+	f.Add("\xff\xfe end x")
+
+	f.Fuzz(func(t *testing.T, text string) {
+		for _, backend := range backends[1:] {
+			if d := CompareBackends(text, backends[0], backend); d != nil {
+				t.Errorf("Text %q: %s", text, d)
+			}
+		}
+	})
+}